@@ -2,7 +2,9 @@ package ast
 
 import (
 	"bytes"
+	"math/big"
 	"monkey/token"
+	"strconv"
 	"strings"
 )
 
@@ -10,6 +12,7 @@ import (
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Pos() token.Position // ノードのソースコード上の位置
 }
 
 // Statement 全てのステートメントノードが実装するインタフェース。
@@ -37,6 +40,14 @@ func (p *Program) TokenLiteral() string {
 	return ""
 }
 
+// Pos ノードの位置を返却する。
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+
 func (p *Program) String() string {
 	out := &strings.Builder{}
 
@@ -59,6 +70,9 @@ func (ls *LetStatement) statementNode() {}
 // TokenLiteral トークンのリテラル値を返す
 func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
 
+// Pos ノードの位置を返却する。
+func (ls *LetStatement) Pos() token.Position { return ls.Token.Pos }
+
 func (ls *LetStatement) String() string {
 	out := &strings.Builder{}
 
@@ -86,6 +100,9 @@ func (rs *ReturnStatement) statementNode() {}
 // TokenLiteral トークンのリテラル値を返す。
 func (rs *ReturnStatement) TokenLiteral() string { return rs.Token.Literal }
 
+// Pos ノードの位置を返却する。
+func (rs *ReturnStatement) Pos() token.Position { return rs.Token.Pos }
+
 func (rs *ReturnStatement) String() string {
 	out := &strings.Builder{}
 
@@ -111,6 +128,9 @@ func (es *ExpressionStatement) statementNode() {}
 // TokenLiteral トークンのリテラル値を返す。
 func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
 
+// Pos ノードの位置を返却する。
+func (es *ExpressionStatement) Pos() token.Position { return es.Token.Pos }
+
 func (es *ExpressionStatement) String() string {
 	if es.Expression != nil {
 		return es.Expression.String()
@@ -129,6 +149,9 @@ func (bs *BlockStatement) statementNode() {}
 // TokenLiteral トークンのリテラル値を返す。
 func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
 
+// Pos ノードの位置を返却する。
+func (bs *BlockStatement) Pos() token.Position { return bs.Token.Pos }
+
 func (bs *BlockStatement) String() string {
 	out := &strings.Builder{}
 
@@ -139,6 +162,160 @@ func (bs *BlockStatement) String() string {
 	return out.String()
 }
 
+// WhileStatement Whileステートメント 例：while (x < 10) { x += 1; }
+type WhileStatement struct {
+	Token     token.Token // token.WHILE トークン
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (ws *WhileStatement) statementNode() {}
+
+// TokenLiteral トークンのリテラル値を返す。
+func (ws *WhileStatement) TokenLiteral() string { return ws.Token.Literal }
+
+// Pos ノードの位置を返却する。
+func (ws *WhileStatement) Pos() token.Position { return ws.Token.Pos }
+
+func (ws *WhileStatement) String() string {
+	out := &strings.Builder{}
+
+	out.WriteString("while")
+	out.WriteString(ws.Condition.String())
+	out.WriteString(" ")
+	out.WriteString(ws.Body.String())
+
+	return out.String()
+}
+
+// ForStatement Forステートメント 例：for (x in [1, 2, 3]) { puts(x); }
+type ForStatement struct {
+	Token    token.Token // token.FOR トークン
+	Variable *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fs *ForStatement) statementNode() {}
+
+// TokenLiteral トークンのリテラル値を返す。
+func (fs *ForStatement) TokenLiteral() string { return fs.Token.Literal }
+
+// Pos ノードの位置を返却する。
+func (fs *ForStatement) Pos() token.Position { return fs.Token.Pos }
+
+func (fs *ForStatement) String() string {
+	out := &strings.Builder{}
+
+	out.WriteString("for (")
+	out.WriteString(fs.Variable.String())
+	out.WriteString(" in ")
+	out.WriteString(fs.Iterable.String())
+	out.WriteString(") ")
+	out.WriteString(fs.Body.String())
+
+	return out.String()
+}
+
+// BreakStatement Breakステートメント
+type BreakStatement struct {
+	Token token.Token // token.BREAK トークン
+}
+
+func (bs *BreakStatement) statementNode() {}
+
+// TokenLiteral トークンのリテラル値を返す。
+func (bs *BreakStatement) TokenLiteral() string { return bs.Token.Literal }
+
+// Pos ノードの位置を返却する。
+func (bs *BreakStatement) Pos() token.Position { return bs.Token.Pos }
+
+func (bs *BreakStatement) String() string { return bs.TokenLiteral() + ";" }
+
+// ContinueStatement Continueステートメント
+type ContinueStatement struct {
+	Token token.Token // token.CONTINUE トークン
+}
+
+func (cs *ContinueStatement) statementNode() {}
+
+// TokenLiteral トークンのリテラル値を返す。
+func (cs *ContinueStatement) TokenLiteral() string { return cs.Token.Literal }
+
+// Pos ノードの位置を返却する。
+func (cs *ContinueStatement) Pos() token.Position { return cs.Token.Pos }
+
+func (cs *ContinueStatement) String() string { return cs.TokenLiteral() + ";" }
+
+// ThrowStatement Throwステートメント
+type ThrowStatement struct {
+	Token token.Token // token.THROW トークン
+	Value Expression
+}
+
+func (ts *ThrowStatement) statementNode() {}
+
+// TokenLiteral トークンのリテラル値を返す。
+func (ts *ThrowStatement) TokenLiteral() string { return ts.Token.Literal }
+
+// Pos ノードの位置を返却する。
+func (ts *ThrowStatement) Pos() token.Position { return ts.Token.Pos }
+
+func (ts *ThrowStatement) String() string {
+	return ts.TokenLiteral() + " " + ts.Value.String() + ";"
+}
+
+// FunctionStatement 関数宣言 例：fn add(x, y) { x + y }
+// 宣言した関数は、宣言を含むブロックの先頭から参照できる。
+type FunctionStatement struct {
+	Token    token.Token // 'fn' トークン
+	Name     *Identifier
+	Function *FunctionLiteral
+}
+
+func (fs *FunctionStatement) statementNode() {}
+
+// TokenLiteral トークンのリテラル値を返す。
+func (fs *FunctionStatement) TokenLiteral() string { return fs.Token.Literal }
+
+// Pos ノードの位置を返却する。
+func (fs *FunctionStatement) Pos() token.Position { return fs.Token.Pos }
+
+func (fs *FunctionStatement) String() string {
+	return fs.TokenLiteral() + " " + fs.Name.String() + "(" + fs.Function.parametersString() + ") " + fs.Function.Body.String()
+}
+
+// ExportStatement export文 例：export let x = 1; export fn f() { }
+// モジュールの最上位でのみ使用でき、束縛した名前をモジュールの外へ公開する。
+type ExportStatement struct {
+	Token     token.Token // token.EXPORT トークン
+	Statement Statement   // *LetStatement または *FunctionStatement
+}
+
+func (es *ExportStatement) statementNode() {}
+
+// TokenLiteral トークンのリテラル値を返す。
+func (es *ExportStatement) TokenLiteral() string { return es.Token.Literal }
+
+// Pos ノードの位置を返却する。
+func (es *ExportStatement) Pos() token.Position { return es.Token.Pos }
+
+func (es *ExportStatement) String() string {
+	return es.TokenLiteral() + " " + es.Statement.String()
+}
+
+// Name 公開する名前を返却する。
+func (es *ExportStatement) Name() string {
+	switch stmt := es.Statement.(type) {
+	case *LetStatement:
+		return stmt.Name.Value
+	case *FunctionStatement:
+		return stmt.Name.Value
+	default:
+		return ""
+	}
+}
+
 // Identifier 識別子
 type Identifier struct {
 	Token token.Token // token.IDENT トークン
@@ -150,6 +327,9 @@ func (i *Identifier) expressionNode() {}
 // TokenLiteral トークンのリテラル値を返す。
 func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
 
+// Pos ノードの位置を返却する。
+func (i *Identifier) Pos() token.Position { return i.Token.Pos }
+
 func (i *Identifier) String() string { return i.Value }
 
 // Boolean 真偽値
@@ -163,12 +343,48 @@ func (b *Boolean) expressionNode() {}
 // TokenLiteral トークンのリテラル値を返す。
 func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
 
+// Pos ノードの位置を返却する。
+func (b *Boolean) Pos() token.Position { return b.Token.Pos }
+
 func (b *Boolean) String() string { return b.Token.Literal }
 
+// NullLiteral null リテラル
+type NullLiteral struct {
+	Token token.Token
+}
+
+func (nl *NullLiteral) expressionNode() {}
+
+// TokenLiteral トークンのリテラル値を返す。
+func (nl *NullLiteral) TokenLiteral() string { return nl.Token.Literal }
+
+// Pos ノードの位置を返却する。
+func (nl *NullLiteral) Pos() token.Position { return nl.Token.Pos }
+
+func (nl *NullLiteral) String() string { return nl.Token.Literal }
+
+// ImportExpression import式 例：import "lib/math"
+type ImportExpression struct {
+	Token token.Token // token.IMPORT トークン
+	Path  string      // 読み込むモジュールのパス
+}
+
+func (ie *ImportExpression) expressionNode() {}
+
+// TokenLiteral トークンのリテラル値を返す。
+func (ie *ImportExpression) TokenLiteral() string { return ie.Token.Literal }
+
+// Pos ノードの位置を返却する。
+func (ie *ImportExpression) Pos() token.Position { return ie.Token.Pos }
+
+func (ie *ImportExpression) String() string { return ie.TokenLiteral() + " " + strconv.Quote(ie.Path) }
+
 // IntegerLiteral 整数リテラル
 type IntegerLiteral struct {
 	Token token.Token
 	Value int64
+	// BigValue 値が int64 に収まらない場合の値。収まる場合は nil となる。
+	BigValue *big.Int
 }
 
 func (il *IntegerLiteral) expressionNode() {}
@@ -176,8 +392,27 @@ func (il *IntegerLiteral) expressionNode() {}
 // TokenLiteral トークンのリテラル値を返す。
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
 
+// Pos ノードの位置を返却する。
+func (il *IntegerLiteral) Pos() token.Position { return il.Token.Pos }
+
 func (il *IntegerLiteral) String() string { return il.Token.Literal }
 
+// FloatLiteral 浮動小数点数リテラル
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode() {}
+
+// TokenLiteral トークンのリテラル値を返す。
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+
+// Pos ノードの位置を返却する。
+func (fl *FloatLiteral) Pos() token.Position { return fl.Token.Pos }
+
+func (fl *FloatLiteral) String() string { return fl.Token.Literal }
+
 // PrefixExpression 前置演算子
 type PrefixExpression struct {
 	Token    token.Token
@@ -190,6 +425,9 @@ func (pe *PrefixExpression) expressionNode() {}
 // TokenLiteral トークンのリテラル値を返す。
 func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
 
+// Pos ノードの位置を返却する。
+func (pe *PrefixExpression) Pos() token.Position { return pe.Token.Pos }
+
 func (pe *PrefixExpression) String() string {
 	out := &strings.Builder{}
 
@@ -214,6 +452,9 @@ func (ie *InfixExpression) expressionNode() {}
 // TokenLiteral トークンのリテラル値を返す。
 func (ie *InfixExpression) TokenLiteral() string { return ie.Token.Literal }
 
+// Pos ノードの位置を返却する。
+func (ie *InfixExpression) Pos() token.Position { return ie.Token.Pos }
+
 func (ie *InfixExpression) String() string {
 	out := &strings.Builder{}
 
@@ -226,6 +467,34 @@ func (ie *InfixExpression) String() string {
 	return out.String()
 }
 
+// AssignExpression 代入式
+type AssignExpression struct {
+	Token    token.Token // 代入演算子トークン、例えば"+="
+	Target   Expression  // 代入先(*Identifier または *IndexExpression)
+	Operator string
+	Value    Expression
+}
+
+func (ae *AssignExpression) expressionNode() {}
+
+// TokenLiteral トークンのリテラル値を返す。
+func (ae *AssignExpression) TokenLiteral() string { return ae.Token.Literal }
+
+// Pos ノードの位置を返却する。
+func (ae *AssignExpression) Pos() token.Position { return ae.Token.Pos }
+
+func (ae *AssignExpression) String() string {
+	out := &strings.Builder{}
+
+	out.WriteString("(")
+	out.WriteString(ae.Target.String())
+	out.WriteString(" " + ae.Operator + " ")
+	out.WriteString(ae.Value.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+
 // IfExpression If式
 type IfExpression struct {
 	Token       token.Token // if トークン
@@ -239,6 +508,9 @@ func (ie *IfExpression) expressionNode() {}
 // TokenLiteral トークンのリテラル値を返す。
 func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
 
+// Pos ノードの位置を返却する。
+func (ie *IfExpression) Pos() token.Position { return ie.Token.Pos }
+
 func (ie *IfExpression) String() string {
 	out := &strings.Builder{}
 
@@ -255,11 +527,53 @@ func (ie *IfExpression) String() string {
 	return out.String()
 }
 
+// TryExpression try式 例：try { f() } catch (e) { e["message"] } finally { g() }
+type TryExpression struct {
+	Token      token.Token // try トークン
+	Block      *BlockStatement
+	CatchParam *Identifier     // catch で捕捉したエラーを束縛する変数(省略時は nil)
+	Catch      *BlockStatement // catch 節(省略時は nil)
+	Finally    *BlockStatement // finally 節(省略時は nil)
+}
+
+func (te *TryExpression) expressionNode() {}
+
+// TokenLiteral トークンのリテラル値を返す。
+func (te *TryExpression) TokenLiteral() string { return te.Token.Literal }
+
+// Pos ノードの位置を返却する。
+func (te *TryExpression) Pos() token.Position { return te.Token.Pos }
+
+func (te *TryExpression) String() string {
+	out := &strings.Builder{}
+
+	out.WriteString("try ")
+	out.WriteString(te.Block.String())
+
+	if te.Catch != nil {
+		out.WriteString(" catch ")
+		if te.CatchParam != nil {
+			out.WriteString("(" + te.CatchParam.String() + ") ")
+		}
+		out.WriteString(te.Catch.String())
+	}
+
+	if te.Finally != nil {
+		out.WriteString(" finally ")
+		out.WriteString(te.Finally.String())
+	}
+
+	return out.String()
+}
+
 // FunctionLiteral 関数識別子
 type FunctionLiteral struct {
 	Token      token.Token // 'fn' トークン
 	Parameters []*Identifier
+	Defaults   []Expression // 仮引数の既定値(Parameters と同じ順序。既定値がない仮引数は nil、既定値を持つ仮引数がない場合は nil)
+	Rest       *Identifier  // 残余引数(...rest)。存在しない場合は nil
 	Body       *BlockStatement
+	Name       string // 関数宣言の名前、または let で束縛された場合の名前
 }
 
 func (fl *FunctionLiteral) expressionNode() {}
@@ -267,28 +581,47 @@ func (fl *FunctionLiteral) expressionNode() {}
 // TokenLiteral トークンのリテラル値を返す。
 func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
 
+// Pos ノードの位置を返却する。
+func (fl *FunctionLiteral) Pos() token.Position { return fl.Token.Pos }
+
 func (fl *FunctionLiteral) String() string {
 	out := &strings.Builder{}
 
-	params := []string{}
-	for _, p := range fl.Parameters {
-		params = append(params, p.String())
-	}
-
 	out.WriteString(fl.TokenLiteral())
+	if fl.Name != "" {
+		out.WriteString("<" + fl.Name + ">")
+	}
 	out.WriteString("(")
-	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(fl.parametersString())
 	out.WriteString(") ")
 	out.WriteString(fl.Body.String())
 
 	return out.String()
 }
 
+// parametersString 仮引数の並びを "a, b = 2, ...r" の形式で返却する。
+func (fl *FunctionLiteral) parametersString() string {
+	params := []string{}
+	for i, p := range fl.Parameters {
+		if fl.Defaults != nil && fl.Defaults[i] != nil {
+			params = append(params, p.String()+" = "+fl.Defaults[i].String())
+		} else {
+			params = append(params, p.String())
+		}
+	}
+	if fl.Rest != nil {
+		params = append(params, "..."+fl.Rest.String())
+	}
+
+	return strings.Join(params, ", ")
+}
+
 // CallExpression 呼び出し式
 type CallExpression struct {
 	Token     token.Token // '(' トークン
 	Function  Expression  // Identifier または FunctionLiteral
 	Arguments []Expression
+	Tail      bool // 関数本体の末尾位置(本体の最後の式または return の値)にある呼び出しか
 }
 
 func (ce *CallExpression) expressionNode() {}
@@ -296,6 +629,9 @@ func (ce *CallExpression) expressionNode() {}
 // TokenLiteral トークンのリテラル値を返す。
 func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
 
+// Pos ノードの位置を返却する。
+func (ce *CallExpression) Pos() token.Position { return ce.Token.Pos }
+
 func (ce *CallExpression) String() string {
 	out := &strings.Builder{}
 
@@ -323,6 +659,9 @@ func (sl *StringLiteral) expressionNode() {}
 // TokenLiteral トークンのリテラル値を返す。
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
 
+// Pos ノードの位置を返却する。
+func (sl *StringLiteral) Pos() token.Position { return sl.Token.Pos }
+
 func (sl *StringLiteral) String() string { return sl.Token.Literal }
 
 // ArrayLiteral 配列リテラル
@@ -336,6 +675,9 @@ func (al *ArrayLiteral) expressionNode() {}
 // TokenLiteral トークンのリテラル値を返す。
 func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
 
+// Pos ノードの位置を返却する。
+func (al *ArrayLiteral) Pos() token.Position { return al.Token.Pos }
+
 func (al *ArrayLiteral) String() string {
 	out := &strings.Builder{}
 
@@ -353,9 +695,10 @@ func (al *ArrayLiteral) String() string {
 
 // IndexExpression 添字
 type IndexExpression struct {
-	Token token.Token // [ トークン
-	Left  Expression
-	Index Expression
+	Token    token.Token // [ または ?[ トークン
+	Left     Expression
+	Index    Expression
+	Optional bool // ?[ の場合は true。左辺が null の場合は添字を評価せずに null となる。
 }
 
 func (ie *IndexExpression) expressionNode() {}
@@ -363,11 +706,17 @@ func (ie *IndexExpression) expressionNode() {}
 // TokenLiteral トークンのリテラル値を返す。
 func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
 
+// Pos ノードの位置を返却する。
+func (ie *IndexExpression) Pos() token.Position { return ie.Token.Pos }
+
 func (ie *IndexExpression) String() string {
 	out := &strings.Builder{}
 
 	out.WriteString("(")
 	out.WriteString(ie.Left.String())
+	if ie.Optional {
+		out.WriteString("?")
+	}
 	out.WriteString("[")
 	out.WriteString(ie.Index.String())
 	out.WriteString("])")
@@ -379,6 +728,7 @@ func (ie *IndexExpression) String() string {
 type HashLiteral struct {
 	Token token.Token // the '{' token
 	Pairs map[Expression]Expression
+	Keys  []Expression // Pairs のキーをソースコードでの出現順に並べたもの
 }
 
 func (hl *HashLiteral) expressionNode() {}
@@ -386,12 +736,15 @@ func (hl *HashLiteral) expressionNode() {}
 // TokenLiteral トークンのリテラル値を返す。
 func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
 
+// Pos ノードの位置を返却する。
+func (hl *HashLiteral) Pos() token.Position { return hl.Token.Pos }
+
 func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, key := range hl.Keys {
+		pairs = append(pairs, key.String()+":"+hl.Pairs[key].String())
 	}
 
 	out.WriteString("{")
@@ -1,18 +1,64 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"monkey/repl"
 	"os"
 	"os/user"
 )
 
+var (
+	engine  = flag.String("engine", string(repl.EngineEval), "use 'eval' or 'vm' (vm supports only a subset of the language: no assignment, while/for, try/throw, fn statements, default/rest parameters or import)")
+	checked = flag.Bool("checked", false, "report integer overflow as an error (eval engine only)")
+)
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage:\n")
+	fmt.Fprintf(flag.CommandLine.Output(), "  monkey [flags]                            start the REPL\n")
+	fmt.Fprintf(flag.CommandLine.Output(), "  monkey [flags] run file.monkey [args...]  run a script file\n")
+	fmt.Fprintf(flag.CommandLine.Output(), "Flags:\n")
+	flag.PrintDefaults()
+}
+
 func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	switch e := repl.Engine(*engine); e {
+	case repl.EngineEval:
+	case repl.EngineVM:
+		if *checked {
+			fmt.Fprintln(os.Stderr, "-checked is not supported by the vm engine")
+			os.Exit(2)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown engine: %s\n", e)
+		os.Exit(2)
+	}
+
+	opts := repl.Options{Engine: repl.Engine(*engine), CheckedArithmetic: *checked}
+
+	if args := flag.Args(); len(args) > 0 {
+		switch args[0] {
+		case "run":
+			if len(args) < 2 {
+				flag.Usage()
+				os.Exit(2)
+			}
+			os.Exit(runFile(opts, args[1], args[2:], os.Stderr))
+		default:
+			fmt.Fprintf(os.Stderr, "unknown command: %s\n", args[0])
+			flag.Usage()
+			os.Exit(2)
+		}
+	}
+
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
 	}
 	fmt.Printf("Hello %s! This is the Monkey programing language! \n", user.Username)
 	fmt.Println("Fell free to type in commands")
-	repl.Start(os.Stdin, os.Stdout)
+	repl.Start(os.Stdin, os.Stdout, opts)
 }
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"monkey/compiler"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/repl"
+	"monkey/vm"
+)
+
+// argsName スクリプト引数を束縛する識別子
+const argsName = "args"
+
+// runFile ファイルを読み込んで実行し、終了コードを返却する。
+func runFile(opts repl.Options, filename string, args []string, stderr io.Writer) int {
+	input, err := ioutil.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	l := lexer.NewWithFilename(filename, string(input))
+	p := parser.New(l)
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		for _, msg := range p.Errors() {
+			fmt.Fprintln(stderr, msg)
+		}
+		return 1
+	}
+
+	argv := newArgsArray(args)
+
+	var result object.Object
+	switch opts.Engine {
+	case repl.EngineVM:
+		symbolTable := compiler.NewSymbolTable()
+		for i, v := range object.Builtins {
+			symbolTable.DefineBuiltin(i, v.Name)
+		}
+		globals := make([]object.Object, vm.GlobalsSize)
+		globals[symbolTable.Define(argsName).Index] = argv
+
+		comp := compiler.NewWithState(symbolTable, []object.Object{})
+		if err := comp.Compile(program); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+
+		machine := vm.NewWithGlobalsStore(comp.Bytecode(), globals)
+		if err := machine.Run(); err != nil {
+			fmt.Fprintln(stderr, "ERROR: "+err.Error())
+			return 1
+		}
+	default:
+		env := object.NewEnvironment()
+		env.Set(argsName, argv)
+		eval := evaluator.New()
+		eval.CheckedArithmetic = opts.CheckedArithmetic
+		result = eval.Eval(program, env)
+	}
+
+	if errObj, ok := result.(*object.Error); ok {
+		fmt.Fprintln(stderr, errObj.Inspect())
+		fmt.Fprint(stderr, errObj.StackTrace())
+		return 1
+	}
+
+	return 0
+}
+
+func newArgsArray(args []string) *object.Array {
+	elements := make([]object.Object, len(args))
+	for i, arg := range args {
+		elements[i] = &object.String{Value: arg}
+	}
+	return &object.Array{Elements: elements}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"monkey/repl"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunFile(t *testing.T) {
+	tests := []struct {
+		input          string
+		args           []string
+		expectedCode   int
+		expectedStderr string
+	}{
+		{`let x = len(args); x;`, []string{"a", "b"}, 0, ""},
+		{`if (len(args) != 1) { 1 + true }`, []string{"ok"}, 0, ""},
+		{`if (len(args) == 0) { 1 + true }`, []string{}, 1, "ERROR: %s:1:25: type mismatch: INTEGER + BOOLEAN\n"},
+		{"let x 5;", []string{}, 1, "%s:1:7: expected next token to be =, got INT instead\n"},
+		{"let f = fn(x) {\n  x / 0\n};\nf(1);", []string{}, 1, "ERROR: %[1]s:2:5: division by zero: 1 / 0\n  at f (%[1]s:4:2)\n"},
+	}
+
+	dir, err := ioutil.TempDir("", "monkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "main.monkey")
+
+	for _, engine := range []repl.Engine{repl.EngineEval, repl.EngineVM} {
+		for _, tt := range tests {
+			if err := ioutil.WriteFile(filename, []byte(tt.input), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			stderr := &bytes.Buffer{}
+			code := runFile(repl.Options{Engine: engine}, filename, tt.args, stderr)
+
+			if code != tt.expectedCode {
+				t.Errorf("[%s] %q: wrong exit code. expected=%d, got=%d (%s)", engine, tt.input, tt.expectedCode, code, stderr)
+			}
+
+			if engine == repl.EngineVM {
+				continue
+			}
+
+			expectedStderr := tt.expectedStderr
+			if expectedStderr != "" {
+				expectedStderr = fmt.Sprintf(expectedStderr, filename)
+			}
+			if stderr.String() != expectedStderr {
+				t.Errorf("[%s] %q: wrong stderr. expected=%q, got=%q", engine, tt.input, expectedStderr, stderr.String())
+			}
+		}
+	}
+}
+
+func TestRunFileNotFound(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	if code := runFile(repl.Options{Engine: repl.EngineEval}, "does-not-exist.monkey", nil, stderr); code != 1 {
+		t.Errorf("wrong exit code. expected=1, got=%d", code)
+	}
+}
+
+func TestRunFileImport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"main.monkey":     `let util = import "lib/util"; if (util["twice"](2) != 4) { 1 + true }`,
+		"lib/util.monkey": `export fn twice(x) { x * 2 }`,
+	}
+	for name, src := range files {
+		filename := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stderr := &bytes.Buffer{}
+	code := runFile(repl.Options{Engine: repl.EngineEval}, filepath.Join(dir, "main.monkey"), nil, stderr)
+	if code != 0 {
+		t.Errorf("wrong exit code. expected=0, got=%d (%s)", code, stderr)
+	}
+}
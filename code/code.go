@@ -0,0 +1,286 @@
+package code
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// Instructions バイトコードの命令列
+type Instructions []byte
+
+func (ins Instructions) String() string {
+	out := &strings.Builder{}
+
+	i := 0
+	for i < len(ins) {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(out, "ERROR: %s\n", err)
+			i++
+			continue
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+
+		fmt.Fprintf(out, "%04d %s\n", i, ins.fmtInstruction(def, operands))
+
+		i += 1 + read
+	}
+
+	return out.String()
+}
+
+func (ins Instructions) fmtInstruction(def *Definition, operands []int) string {
+	operandCount := len(def.OperandWidths)
+
+	if len(operands) != operandCount {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d\n", len(operands), operandCount)
+	}
+
+	switch operandCount {
+	case 0:
+		return def.Name
+	case 1:
+		return fmt.Sprintf("%s %d", def.Name, operands[0])
+	case 2:
+		return fmt.Sprintf("%s %d %d", def.Name, operands[0], operands[1])
+	}
+
+	return fmt.Sprintf("ERROR: unhandled operandCount for %s\n", def.Name)
+}
+
+// Opcode 命令コード
+type Opcode byte
+
+const (
+	// OpConstant 定数プールの値をスタックに積む。
+	OpConstant Opcode = iota
+
+	// OpAdd スタックから2つの値を取り出し、加算結果を積む。
+	OpAdd
+	// OpSub スタックから2つの値を取り出し、減算結果を積む。
+	OpSub
+	// OpMul スタックから2つの値を取り出し、乗算結果を積む。
+	OpMul
+	// OpDiv スタックから2つの値を取り出し、除算結果を積む。
+	OpDiv
+	// OpMod スタックから2つの値を取り出し、剰余を積む。
+	OpMod
+	// OpBitAnd スタックから2つの値を取り出し、ビット積を積む。
+	OpBitAnd
+	// OpBitOr スタックから2つの値を取り出し、ビット和を積む。
+	OpBitOr
+	// OpBitXor スタックから2つの値を取り出し、排他的論理和を積む。
+	OpBitXor
+	// OpShiftLeft スタックから2つの値を取り出し、左シフトの結果を積む。
+	OpShiftLeft
+	// OpShiftRight スタックから2つの値を取り出し、右シフトの結果を積む。
+	OpShiftRight
+
+	// OpPop スタックの先頭の値を取り除く。
+	OpPop
+
+	// OpTrue true をスタックに積む。
+	OpTrue
+	// OpFalse false をスタックに積む。
+	OpFalse
+
+	// OpEqual ==
+	OpEqual
+	// OpNotEqual !=
+	OpNotEqual
+	// OpGreaterThan >
+	OpGreaterThan
+	// OpGreaterThanOrEqual >=
+	OpGreaterThanOrEqual
+	// OpLessThan <
+	OpLessThan
+	// OpLessThanOrEqual <=
+	OpLessThanOrEqual
+
+	// OpMinus 前置演算子 -
+	OpMinus
+	// OpBang 前置演算子 !
+	OpBang
+
+	// OpJumpNotTruthy スタックの先頭が真でなければオペランドの位置へジャンプする。
+	OpJumpNotTruthy
+	// OpJump オペランドの位置へ無条件にジャンプする。
+	OpJump
+	// OpJumpNull スタックの先頭が null であればオペランドの位置へジャンプする。先頭の値は取り除かない。
+	OpJumpNull
+	// OpJumpNotNull スタックの先頭が null でなければ、その値を残したままオペランドの位置へジャンプする。
+	// null であれば先頭の値を取り除いて次の命令へ進む。
+	OpJumpNotNull
+
+	// OpNull null をスタックに積む。
+	OpNull
+
+	// OpGetGlobal グローバル変数の値をスタックに積む。
+	OpGetGlobal
+	// OpSetGlobal スタックの先頭の値をグローバル変数に束縛する。
+	OpSetGlobal
+
+	// OpArray スタック上の要素から配列を生成する。
+	OpArray
+	// OpHash スタック上のキーと値からハッシュを生成する。
+	OpHash
+	// OpIndex 添字アクセスを行う。
+	OpIndex
+
+	// OpCall 関数を呼び出す。オペランドは引数の数。
+	OpCall
+	// OpReturnValue スタックの先頭の値を戻り値として関数から戻る。
+	OpReturnValue
+	// OpReturn 戻り値なしで関数から戻る。
+	OpReturn
+
+	// OpGetLocal ローカル変数の値をスタックに積む。
+	OpGetLocal
+	// OpSetLocal スタックの先頭の値をローカル変数に束縛する。
+	OpSetLocal
+
+	// OpGetBuiltin 組み込み関数をスタックに積む。
+	OpGetBuiltin
+
+	// OpClosure 定数プールの関数と自由変数からクロージャを生成する。
+	OpClosure
+	// OpGetFree 自由変数の値をスタックに積む。
+	OpGetFree
+	// OpCurrentClosure 実行中のクロージャ自身をスタックに積む。
+	OpCurrentClosure
+)
+
+// Definition 命令コードの定義
+type Definition struct {
+	Name          string
+	OperandWidths []int // 各オペランドのバイト幅
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant: {"OpConstant", []int{2}},
+
+	OpAdd: {"OpAdd", []int{}},
+	OpSub: {"OpSub", []int{}},
+	OpMul: {"OpMul", []int{}},
+	OpDiv: {"OpDiv", []int{}},
+	OpMod: {"OpMod", []int{}},
+
+	OpBitAnd:     {"OpBitAnd", []int{}},
+	OpBitOr:      {"OpBitOr", []int{}},
+	OpBitXor:     {"OpBitXor", []int{}},
+	OpShiftLeft:  {"OpShiftLeft", []int{}},
+	OpShiftRight: {"OpShiftRight", []int{}},
+
+	OpPop: {"OpPop", []int{}},
+
+	OpTrue:  {"OpTrue", []int{}},
+	OpFalse: {"OpFalse", []int{}},
+
+	OpEqual:              {"OpEqual", []int{}},
+	OpNotEqual:           {"OpNotEqual", []int{}},
+	OpGreaterThan:        {"OpGreaterThan", []int{}},
+	OpGreaterThanOrEqual: {"OpGreaterThanOrEqual", []int{}},
+	OpLessThan:           {"OpLessThan", []int{}},
+	OpLessThanOrEqual:    {"OpLessThanOrEqual", []int{}},
+
+	OpMinus: {"OpMinus", []int{}},
+	OpBang:  {"OpBang", []int{}},
+
+	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}},
+	OpJump:          {"OpJump", []int{2}},
+	OpJumpNull:      {"OpJumpNull", []int{2}},
+	OpJumpNotNull:   {"OpJumpNotNull", []int{2}},
+
+	OpNull: {"OpNull", []int{}},
+
+	OpGetGlobal: {"OpGetGlobal", []int{2}},
+	OpSetGlobal: {"OpSetGlobal", []int{2}},
+
+	OpArray: {"OpArray", []int{2}},
+	OpHash:  {"OpHash", []int{2}},
+	OpIndex: {"OpIndex", []int{}},
+
+	OpCall:        {"OpCall", []int{1}},
+	OpReturnValue: {"OpReturnValue", []int{}},
+	OpReturn:      {"OpReturn", []int{}},
+
+	OpGetLocal: {"OpGetLocal", []int{1}},
+	OpSetLocal: {"OpSetLocal", []int{1}},
+
+	OpGetBuiltin: {"OpGetBuiltin", []int{1}},
+
+	OpClosure:        {"OpClosure", []int{2, 1}},
+	OpGetFree:        {"OpGetFree", []int{1}},
+	OpCurrentClosure: {"OpCurrentClosure", []int{}},
+}
+
+// Lookup 命令コードの定義を返却する。
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+
+	return def, nil
+}
+
+// Make 命令コードとオペランドから1つの命令を生成する。
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	instructionLen := 1
+	for _, w := range def.OperandWidths {
+		instructionLen += w
+	}
+
+	instruction := make([]byte, instructionLen)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		width := def.OperandWidths[i]
+		switch width {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += width
+	}
+
+	return instruction
+}
+
+// ReadOperands 命令のオペランドを読み取り、読み取ったバイト数と共に返却する。
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+
+		offset += width
+	}
+
+	return operands, offset
+}
+
+// ReadUint16 2バイトのオペランドを読み取る。
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+// ReadUint8 1バイトのオペランドを読み取る。
+func ReadUint8(ins Instructions) uint8 {
+	return uint8(ins[0])
+}
@@ -0,0 +1,129 @@
+package compiler
+
+// SymbolScope シンボルのスコープ
+type SymbolScope string
+
+const (
+	// GlobalScope グローバルスコープ
+	GlobalScope SymbolScope = "GLOBAL"
+	// LocalScope ローカルスコープ
+	LocalScope SymbolScope = "LOCAL"
+	// BuiltinScope 組み込み関数のスコープ
+	BuiltinScope SymbolScope = "BUILTIN"
+	// FreeScope 自由変数のスコープ
+	FreeScope SymbolScope = "FREE"
+	// FunctionScope 実行中の関数自身を指すスコープ
+	FunctionScope SymbolScope = "FUNCTION"
+)
+
+// Symbol 識別子に対応するシンボル
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+}
+
+// SymbolTable 識別子とシンボルを対応付けるテーブル
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store          map[string]Symbol
+	numDefinitions int
+
+	FreeSymbols []Symbol
+}
+
+// NewSymbolTable シンボルテーブルを生成する。
+func NewSymbolTable() *SymbolTable {
+	s := make(map[string]Symbol)
+	return &SymbolTable{store: s, FreeSymbols: []Symbol{}}
+}
+
+// NewEnclosedSymbolTable シンボルテーブルの中にシンボルテーブルを生成する。
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+// Define 識別子をシンボルとして定義する。
+// 同じスコープで定義済みの識別子を再定義した場合は、同じ領域を再利用する。
+func (s *SymbolTable) Define(name string) Symbol {
+	if symbol, ok := s.store[name]; ok && (symbol.Scope == GlobalScope || symbol.Scope == LocalScope) {
+		return symbol
+	}
+
+	symbol := Symbol{Name: name, Index: s.numDefinitions}
+	if s.Outer == nil {
+		symbol.Scope = GlobalScope
+	} else {
+		symbol.Scope = LocalScope
+	}
+
+	s.store[name] = symbol
+	s.numDefinitions++
+	return symbol
+}
+
+// DefineBuiltin 組み込み関数をシンボルとして定義する。
+func (s *SymbolTable) DefineBuiltin(index int, name string) Symbol {
+	symbol := Symbol{Name: name, Index: index, Scope: BuiltinScope}
+	s.store[name] = symbol
+	return symbol
+}
+
+// DefineFunctionName 実行中の関数自身の名前をシンボルとして定義する。
+func (s *SymbolTable) DefineFunctionName(name string) Symbol {
+	symbol := Symbol{Name: name, Index: 0, Scope: FunctionScope}
+	s.store[name] = symbol
+	return symbol
+}
+
+// Resolve 識別子に対応するシンボルを返却する。
+// 外側のローカルスコープで定義されたシンボルは自由変数として扱う。
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	obj, ok := s.store[name]
+	if !ok && s.Outer != nil {
+		obj, ok = s.Outer.Resolve(name)
+		if !ok {
+			return obj, ok
+		}
+
+		if obj.Scope == GlobalScope || obj.Scope == BuiltinScope {
+			return obj, ok
+		}
+
+		free := s.defineFree(obj)
+		return free, true
+	}
+	return obj, ok
+}
+
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	s.FreeSymbols = append(s.FreeSymbols, original)
+
+	symbol := Symbol{Name: original.Name, Index: len(s.FreeSymbols) - 1}
+	symbol.Scope = FreeScope
+
+	s.store[original.Name] = symbol
+	return symbol
+}
+
+// Copy シンボルテーブルの複製を返却する。
+// 複製への定義は元のテーブルに影響しない。
+func (s *SymbolTable) Copy() *SymbolTable {
+	store := make(map[string]Symbol, len(s.store))
+	for name, symbol := range s.store {
+		store[name] = symbol
+	}
+
+	free := make([]Symbol, len(s.FreeSymbols))
+	copy(free, s.FreeSymbols)
+
+	return &SymbolTable{
+		Outer:          s.Outer,
+		store:          store,
+		numDefinitions: s.numDefinitions,
+		FreeSymbols:    free,
+	}
+}
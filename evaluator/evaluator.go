@@ -1,129 +1,265 @@
 package evaluator
 
 import (
+	"context"
 	"fmt"
+	"math"
+	"math/big"
 	"monkey/ast"
 	"monkey/object"
+	"strconv"
+	"strings"
 )
 
+// tailCallObj 末尾呼び出しを表す内部オブジェクトの種別
+const tailCallObj = "TAIL_CALL"
+
+// tailCall 評価を保留した末尾位置の呼び出し。関数本体の評価結果として applyFunction へ返却される。
+type tailCall struct {
+	call *ast.CallExpression
+	fn   object.Object
+	args []object.Object
+}
+
+// Type オブジェクトのタイプを返却する。
+func (tc *tailCall) Type() object.Type { return tailCallObj }
+
+// Inspect オブジェクトの値を返却する。
+func (tc *tailCall) Inspect() string { return "tail call" }
+
 var (
-	null     = &object.Null{}
-	trueObj  = &object.Boolean{Value: true}
-	falseObj = &object.Boolean{Value: false}
+	null           = object.NullValue
+	trueObj        = object.True
+	falseObj       = object.False
+	breakSignal    = &object.Break{}
+	continueSignal = &object.Continue{}
 )
 
-// Eval Nodeの評価を行い、オブジェクトを返却する。
+// Evaluator 評価器。評価時の設定を保持する。
+type Evaluator struct {
+	// CheckedArithmetic 整数演算でオーバーフローが発生した場合に、BigIntへ昇格させずにエラーとする。
+	CheckedArithmetic bool
+	// ReadFile import するモジュールのソースコードを読み込む。nil の場合は ioutil.ReadFile を使用する。
+	ReadFile func(filename string) ([]byte, error)
+	// Builtins 標準の組み込み関数に加えて使用する組み込み関数。import したモジュールからも参照できる。
+	Builtins map[string]*object.Builtin
+	// MaxSteps 評価するノード数の上限。0 の場合は制限しない。EvalContext の呼び出しごとに数え直す。
+	MaxSteps int
+	// MaxCallDepth 関数呼び出しの入れ子の深さの上限。0 の場合は制限しない。末尾呼び出しは深さを増やさない。
+	MaxCallDepth int
+	// MaxAlloc 生成する配列、ハッシュ、文字列、多倍長整数の大きさ(バイト数の概算)の合計の上限。0 の場合は制限しない。
+	// 解放された値も差し引かずに数え、EvalContext の呼び出しごとに数え直す。
+	MaxAlloc int
+
+	modules map[string]*object.Module // 読み込み済みのモジュール(パスをキーとする)
+	loading []string                  // 読み込み中のモジュールのパス(循環 import の検出に使用する)
+
+	contexts  []context.Context // 評価の中断に使用するコンテキスト(EvalContext の入れ子の順に格納する)
+	steps     int               // 評価したノード数
+	callDepth int               // 関数呼び出しの入れ子の深さ
+	allocated int               // 生成した配列、ハッシュ、文字列、多倍長整数の大きさの合計
+}
+
+// New 評価器を生成する。
+func New() *Evaluator {
+	return &Evaluator{MaxCallDepth: DefaultMaxCallDepth}
+}
+
+// Eval 既定の設定でNodeの評価を行い、オブジェクトを返却する。
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	return New().Eval(node, env)
+}
+
+// Eval Nodeの評価を行い、オブジェクトを返却する。
+// 評価中に発生したエラーには、エラーを発生させた式の位置が付与される。
+func (e *Evaluator) Eval(node ast.Node, env *object.Environment) object.Object {
+	var result object.Object
+	if err := e.step(); err != nil {
+		result = err
+	} else {
+		result = e.eval(node, env)
+	}
+
+	if err, ok := result.(*object.Error); ok && !err.Pos.IsValid() {
+		err.Pos = node.Pos()
+	}
+
+	return result
+}
+
+func (e *Evaluator) eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 
 	// Statements
 	case *ast.Program:
-		return evalProgram(node.Statements, env)
+		return e.evalProgram(node.Statements, env)
 
 	case *ast.BlockStatement:
-		return evalBlockStatement(node, env)
+		return e.evalBlockStatement(node, env)
 
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression, env)
+		return e.Eval(node.Expression, env)
 
 	case *ast.ReturnStatement:
-		val := Eval(node.ReturnValue, env)
-		if isError(val) {
+		val := e.Eval(node.ReturnValue, env)
+		if isAbrupt(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
 
 	case *ast.LetStatement:
-		val := Eval(node.Value, env)
-		if isError(val) {
+		val := e.Eval(node.Value, env)
+		if isAbrupt(val) {
 			return val
 		}
 		env.Set(node.Name.Value, val)
 
+	case *ast.WhileStatement:
+		return e.evalWhileStatement(node, env)
+
+	case *ast.ForStatement:
+		return e.evalForStatement(node, env)
+
+	case *ast.BreakStatement:
+		return breakSignal
+
+	case *ast.ContinueStatement:
+		return continueSignal
+
+	case *ast.FunctionStatement:
+		// 関数はブロックの評価開始時に束縛済み(hoistFunctions)のため、ここでは何もしない。
+		return nil
+
+	case *ast.ExportStatement:
+		return e.Eval(node.Statement, env)
+
+	case *ast.ThrowStatement:
+		val := e.Eval(node.Value, env)
+		if isAbrupt(val) {
+			return val
+		}
+		return evalThrow(val)
+
 	// Expressions
 	case *ast.IntegerLiteral:
+		if node.BigValue != nil {
+			return e.allocate(object.NewBigInt(node.BigValue))
+		}
 		return &object.Integer{Value: node.Value}
 
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+
 	case *ast.StringLiteral:
-		return &object.String{Value: node.Value}
+		return e.allocate(&object.String{Value: node.Value})
 
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 
+	case *ast.NullLiteral:
+		return null
+
 	case *ast.PrefixExpression:
-		right := Eval(node.Right, env)
-		if isError(right) {
+		right := e.Eval(node.Right, env)
+		if isAbrupt(right) {
 			return right
 		}
-		return evalPrefixExpression(node.Operator, right)
+		return e.evalPrefixExpression(node.Operator, right)
 
 	case *ast.InfixExpression:
-		left := Eval(node.Left, env)
-		if isError(left) {
+		if node.Operator == "&&" || node.Operator == "||" {
+			return e.evalLogicalExpression(node, env)
+		}
+		if node.Operator == "??" {
+			return e.evalNullCoalescingExpression(node, env)
+		}
+
+		left := e.Eval(node.Left, env)
+		if isAbrupt(left) {
 			return left
 		}
 
-		right := Eval(node.Right, env)
-		if isError(right) {
+		right := e.Eval(node.Right, env)
+		if isAbrupt(right) {
 			return right
 		}
-		return evalInfixExpression(node.Operator, left, right)
+		return e.evalInfixExpression(node.Operator, left, right)
 
 	case *ast.IfExpression:
-		return evalIfExpression(node, env)
+		return e.evalIfExpression(node, env)
+
+	case *ast.TryExpression:
+		return e.evalTryExpression(node, env)
+
+	case *ast.ImportExpression:
+		return e.evalImportExpression(node)
 
 	case *ast.Identifier:
-		return evalIdentifier(node, env)
+		return e.evalIdentifier(node, env)
 
 	case *ast.FunctionLiteral:
-		params := node.Parameters
-		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return newFunction(node, env)
 
 	case *ast.CallExpression:
-		function := Eval(node.Function, env)
-		if isError(function) {
+		function := e.Eval(node.Function, env)
+		if isAbrupt(function) {
 			return function
 		}
 
-		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
+		args := e.evalExpressions(node.Arguments, env)
+		if len(args) == 1 && isAbrupt(args[0]) {
 			return args[0]
 		}
 
-		return applyFunction(function, args)
+		if node.Tail {
+			return &tailCall{call: node, fn: function, args: args}
+		}
+
+		result := e.applyFunction(function, args)
+		if err, ok := result.(*object.Error); ok {
+			pushStackFrame(err, node, function)
+		}
+		return result
 
 	case *ast.ArrayLiteral:
-		elements := evalExpressions(node.Elements, env)
-		if len(elements) == 1 && isError(elements[0]) {
+		elements := e.evalExpressions(node.Elements, env)
+		if len(elements) == 1 && isAbrupt(elements[0]) {
 			return elements[0]
 		}
-		return &object.Array{Elements: elements}
+		return e.allocate(&object.Array{Elements: elements})
 
 	case *ast.IndexExpression:
-		left := Eval(node.Left, env)
-		if isError(left) {
+		left := e.Eval(node.Left, env)
+		if isAbrupt(left) {
 			return left
 		}
-		index := Eval(node.Index, env)
-		if isError(index) {
+		if node.Optional && left == null {
+			return null
+		}
+		index := e.Eval(node.Index, env)
+		if isAbrupt(index) {
 			return index
 		}
-		return evalIndexExpression(left, index)
+		return e.evalIndexExpression(left, index)
 
 	case *ast.HashLiteral:
-		return evalHashLiteral(node, env)
+		return e.evalHashLiteral(node, env)
+
+	case *ast.AssignExpression:
+		return e.evalAssignExpression(node, env)
 
 	}
 
 	return nil
 }
 
-func evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
+func (e *Evaluator) evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
 	var result object.Object
 
+	hoistFunctions(stmts, env)
+
 	for _, statement := range stmts {
-		result = Eval(statement, env)
+		result = e.Eval(statement, env)
 
 		switch result := result.(type) {
 		case *object.ReturnValue:
@@ -136,15 +272,17 @@ func evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
 	return result
 }
 
-func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+func (e *Evaluator) evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
 	var result object.Object
 
+	hoistFunctions(block.Statements, env)
+
 	for _, statement := range block.Statements {
-		result = Eval(statement, env)
+		result = e.Eval(statement, env)
 
 		if result != nil {
-			rt := result.Type()
-			if rt == object.ReturnValueObj || rt == object.ErrorObj {
+			switch result.Type() {
+			case object.ReturnValueObj, object.ErrorObj, object.BreakObj, object.ContinueObj:
 				return result
 			}
 		}
@@ -153,6 +291,153 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 	return result
 }
 
+// evalWhileStatement 条件が真である間、本体を繰り返し評価する。
+// 本体は while 文と同じ環境で評価される。
+func (e *Evaluator) evalWhileStatement(node *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		condition := e.Eval(node.Condition, env)
+		if isAbrupt(condition) {
+			return condition
+		}
+
+		if !isTruthy(condition) {
+			return nil
+		}
+
+		result := e.Eval(node.Body, env)
+		if result, done := loopResult(result); done {
+			return result
+		}
+	}
+}
+
+// evalForStatement 配列の要素、ハッシュのキー、文字列の文字を順に変数へ束縛して本体を評価する。
+// 変数は繰り返しごとに生成される環境に束縛されるため、本体で生成したクロージャはその回の値を保持する。
+func (e *Evaluator) evalForStatement(node *ast.ForStatement, env *object.Environment) object.Object {
+	iterable := e.Eval(node.Iterable, env)
+	if isAbrupt(iterable) {
+		return iterable
+	}
+
+	var items []object.Object
+
+	switch iterable := iterable.(type) {
+	case *object.Array:
+		items = iterable.Elements
+	case *object.Hash:
+		items = iterable.Keys()
+	case *object.String:
+		for _, r := range iterable.Value {
+			item := e.allocate(&object.String{Value: string(r)})
+			if isError(item) {
+				return item
+			}
+			items = append(items, item)
+		}
+	default:
+		return newError(object.TypeErrorKind, "not iterable: %s", iterable.Type())
+	}
+
+	for _, item := range items {
+		loopEnv := object.NewEnclosedEnvironment(env)
+		loopEnv.Set(node.Variable.Value, item)
+
+		result := e.Eval(node.Body, loopEnv)
+		if result, done := loopResult(result); done {
+			return result
+		}
+	}
+
+	return nil
+}
+
+// newFunction 関数リテラルと定義時の環境から関数を生成する。
+func newFunction(node *ast.FunctionLiteral, env *object.Environment) *object.Function {
+	return &object.Function{
+		Name:       node.Name,
+		Parameters: node.Parameters,
+		Defaults:   node.Defaults,
+		Rest:       node.Rest,
+		Body:       node.Body,
+		Env:        env,
+	}
+}
+
+// hoistFunctions 文の並びに含まれる関数宣言を、文を評価する前に環境へ束縛する。
+// これにより、宣言より前の文からの呼び出しや、同じ階層の関数同士の相互再帰が可能となる。
+func hoistFunctions(stmts []ast.Statement, env *object.Environment) {
+	for _, stmt := range stmts {
+		if es, ok := stmt.(*ast.ExportStatement); ok {
+			stmt = es.Statement
+		}
+		if fs, ok := stmt.(*ast.FunctionStatement); ok {
+			env.Set(fs.Name.Value, newFunction(fs.Function, env))
+		}
+	}
+}
+
+// evalThrow 値を送出するエラーを生成する。catch で捕捉したエラーを送出した場合は、元のエラーをそのまま再送出する。
+func evalThrow(val object.Object) object.Object {
+	if exc, ok := val.(*object.Exception); ok {
+		return exc.Err
+	}
+
+	return &object.Error{Kind: object.ErrorKind, Message: val.Inspect(), Value: val}
+}
+
+// evalTryExpression try式を評価する。
+// 本体でエラーが発生した場合は catch 節を評価し、その結果を try式の値とする。
+// finally 節は常に評価し、finally 節でのエラー、return、break、continue はそれまでの結果より優先する。
+func (e *Evaluator) evalTryExpression(node *ast.TryExpression, env *object.Environment) object.Object {
+	result := e.Eval(node.Block, env)
+
+	// 実行制限による中断は catch で捕捉できず、finally 節も評価しない。
+	if err, ok := result.(*object.Error); ok && isLimitError(err) {
+		return err
+	}
+
+	if err, ok := result.(*object.Error); ok && node.Catch != nil {
+		catchEnv := object.NewEnclosedEnvironment(env)
+		if node.CatchParam != nil {
+			catchEnv.Set(node.CatchParam.Value, &object.Exception{Err: err})
+		}
+		result = e.Eval(node.Catch, catchEnv)
+	}
+
+	if err, ok := result.(*object.Error); ok && isLimitError(err) {
+		return err
+	}
+
+	if node.Finally != nil {
+		finallyResult := e.Eval(node.Finally, env)
+		if finallyResult != nil {
+			switch finallyResult.Type() {
+			case object.ErrorObj, object.ReturnValueObj, object.BreakObj, object.ContinueObj:
+				return finallyResult
+			}
+		}
+	}
+
+	return result
+}
+
+// loopResult ループ本体の評価結果を解釈し、ループを終了する場合は true とループの結果を返却する。
+// return とエラーはループの外へ伝播させる。
+func loopResult(result object.Object) (object.Object, bool) {
+	if result == nil {
+		return nil, false
+	}
+
+	switch result.Type() {
+	case object.BreakObj:
+		return nil, true
+	case object.ReturnValueObj, object.ErrorObj:
+		return result, true
+	default:
+		return nil, false
+	}
+}
+
 func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	if input {
 		return trueObj
@@ -160,32 +445,72 @@ func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	return falseObj
 }
 
-func evalPrefixExpression(operator string, right object.Object) object.Object {
+func (e *Evaluator) evalPrefixExpression(operator string, right object.Object) object.Object {
 	switch operator {
 	case "!":
 		return evalBangOperatorExpression(right)
 	case "-":
-		return evalMinusPrefixOperatorExpression(right)
+		return e.evalMinusPrefixOperatorExpression(right)
 	default:
-		return newError("unknown operator: %s%s", operator, right.Type())
+		return newError(object.TypeErrorKind, "unknown operator: %s%s", operator, right.Type())
 	}
 }
 
-func evalInfixExpression(operator string, left, right object.Object) object.Object {
+func (e *Evaluator) evalInfixExpression(operator string, left, right object.Object) object.Object {
 	switch {
 	case left.Type() == object.IntegerObj && right.Type() == object.IntegerObj:
-		return evalIntegerInfixExpression(operator, left, right)
+		return e.evalIntegerInfixExpression(operator, left, right)
+	case isInteger(left) && isInteger(right):
+		return e.evalBigIntInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(operator, left, right)
 	case left.Type() == object.StringObj && right.Type() == object.StringObj:
-		return evalStringInfixExpression(operator, left, right)
+		return e.evalStringInfixExpression(operator, left, right)
+	case left.Type() == object.HashObj && right.Type() == object.HashObj && (operator == "==" || operator == "!="):
+		equal := left.(*object.Hash).Equal(right.(*object.Hash))
+		return nativeBoolToBooleanObject(equal == (operator == "=="))
 	case operator == "==":
 		return nativeBoolToBooleanObject(left == right)
 	case operator == "!=":
 		return nativeBoolToBooleanObject(left != right)
 	case left.Type() != right.Type():
-		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+		return newError(object.TypeErrorKind, "type mismatch: %s %s %s", left.Type(), operator, right.Type())
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError(object.TypeErrorKind, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// evalLogicalExpression && と || を評価する。右辺は結果が左辺だけで決まらない場合にのみ評価する。
+// 結果は両辺の真偽(isTruthy)から求めた真偽値となる。
+func (e *Evaluator) evalLogicalExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
+	left := e.Eval(node.Left, env)
+	if isAbrupt(left) {
+		return left
+	}
+
+	if node.Operator == "&&" && !isTruthy(left) {
+		return falseObj
+	}
+	if node.Operator == "||" && isTruthy(left) {
+		return trueObj
+	}
+
+	right := e.Eval(node.Right, env)
+	if isAbrupt(right) {
+		return right
+	}
+
+	return nativeBoolToBooleanObject(isTruthy(right))
+}
+
+// evalNullCoalescingExpression ?? を評価する。左辺が null の場合にのみ右辺を評価し、その値を返却する。
+func (e *Evaluator) evalNullCoalescingExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
+	left := e.Eval(node.Left, env)
+	if isAbrupt(left) || left != null {
+		return left
 	}
+
+	return e.Eval(node.Right, env)
 }
 
 func evalBangOperatorExpression(right object.Object) object.Object {
@@ -201,76 +526,248 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 	}
 }
 
-func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.IntegerObj {
-		return newError("unknown operator: -%s", right.Type())
+func (e *Evaluator) evalMinusPrefixOperatorExpression(right object.Object) object.Object {
+	switch right := right.(type) {
+	case *object.Integer:
+		if right.Value == math.MinInt64 {
+			if e.CheckedArithmetic {
+				return newError(object.OverflowErrorKind, "integer overflow: -(%d)", right.Value)
+			}
+			return e.allocate(object.NewBigInt(new(big.Int).Neg(big.NewInt(right.Value))))
+		}
+		return &object.Integer{Value: -right.Value}
+	case *object.BigInt:
+		return e.allocate(object.NewBigInt(new(big.Int).Neg(right.Value)))
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
+		return newError(object.TypeErrorKind, "unknown operator: -%s", right.Type())
 	}
-
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
 }
 
-func evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
+func (e *Evaluator) evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
 	leftVal := left.(*object.Integer).Value
 	rightVal := right.(*object.Integer).Value
 
+	switch operator {
+	case "+", "-", "*", "<<":
+		if operator == "<<" && rightVal < 0 {
+			return newError(object.ValueErrorKind, "negative shift count: %d", rightVal)
+		}
+		result, ok := object.IntArithmetic(operator, leftVal, rightVal)
+		if !ok {
+			if e.CheckedArithmetic {
+				return newError(object.OverflowErrorKind, "integer overflow: %d %s %d", leftVal, operator, rightVal)
+			}
+			return e.evalBigIntInfixExpression(operator, left, right)
+		}
+		return &object.Integer{Value: result}
+	case "/":
+		if rightVal == 0 {
+			return newError(object.ZeroDivisionErrorKind, "division by zero: %d / %d", leftVal, rightVal)
+		}
+		if leftVal == math.MinInt64 && rightVal == -1 {
+			if e.CheckedArithmetic {
+				return newError(object.OverflowErrorKind, "integer overflow: %d / %d", leftVal, rightVal)
+			}
+			return e.evalBigIntInfixExpression(operator, left, right)
+		}
+		return &object.Integer{Value: leftVal / rightVal}
+	case "%":
+		if rightVal == 0 {
+			return newError(object.ZeroDivisionErrorKind, "division by zero: %d %% %d", leftVal, rightVal)
+		}
+		return &object.Integer{Value: leftVal % rightVal}
+	case ">>":
+		if rightVal < 0 {
+			return newError(object.ValueErrorKind, "negative shift count: %d", rightVal)
+		}
+		return &object.Integer{Value: leftVal >> uint64(rightVal)}
+	case "&":
+		return &object.Integer{Value: leftVal & rightVal}
+	case "|":
+		return &object.Integer{Value: leftVal | rightVal}
+	case "^":
+		return &object.Integer{Value: leftVal ^ rightVal}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError(object.TypeErrorKind, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// evalBigIntInfixExpression 少なくとも一方が int64 に収まらない整数同士の演算を行う。
+// 演算結果が int64 に収まる場合は Integer に戻す。
+// 算術演算の結果の大きさの上限は、演算する前に割り当て量へ加算する。
+func (e *Evaluator) evalBigIntInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := object.ToBigInt(left)
+	rightVal := object.ToBigInt(right)
+
+	switch operator {
+	case "+", "-", "&", "|", "^":
+		if err := e.reserve(bigIntSize(maxInt(leftVal.BitLen(), rightVal.BitLen()) + 1)); err != nil {
+			return err
+		}
+		return object.BigIntArithmetic(operator, leftVal, rightVal)
+	case "*":
+		if err := e.reserve(bigIntSize(leftVal.BitLen() + rightVal.BitLen())); err != nil {
+			return err
+		}
+		return object.BigIntArithmetic(operator, leftVal, rightVal)
+	case "/", "%":
+		if rightVal.Sign() == 0 {
+			return newError(object.ZeroDivisionErrorKind, "division by zero: %s %s %s", leftVal, operator, rightVal)
+		}
+		if err := e.reserve(bigIntSize(leftVal.BitLen() + 1)); err != nil {
+			return err
+		}
+		return object.BigIntArithmetic(operator, leftVal, rightVal)
+	case "<<", ">>":
+		if rightVal.Sign() < 0 {
+			return newError(object.ValueErrorKind, "negative shift count: %s", rightVal)
+		}
+		if !rightVal.IsInt64() || rightVal.Int64() > object.MaxShiftCount {
+			return newError(object.ValueErrorKind, "shift count too large: %s", rightVal)
+		}
+		bits := leftVal.BitLen()
+		if operator == "<<" {
+			bits += int(rightVal.Int64())
+		}
+		if err := e.reserve(bigIntSize(bits)); err != nil {
+			return err
+		}
+		return object.BigIntArithmetic(operator, leftVal, rightVal)
+	case "<":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) < 0)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) > 0)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) <= 0)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) >= 0)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) == 0)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) != 0)
+	default:
+		return newError(object.TypeErrorKind, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// evalFloatInfixExpression 少なくとも一方が浮動小数点数である数値同士の演算を行う。
+// 整数は浮動小数点数に変換してから演算するため、結果は常に浮動小数点数(比較演算では真偽値)となる。
+func evalFloatInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
+
 	switch operator {
 	case "+":
-		return &object.Integer{Value: leftVal + rightVal}
+		return &object.Float{Value: leftVal + rightVal}
 	case "-":
-		return &object.Integer{Value: leftVal - rightVal}
+		return &object.Float{Value: leftVal - rightVal}
 	case "*":
-		return &object.Integer{Value: leftVal * rightVal}
-	case "/":
-		return &object.Integer{Value: leftVal / rightVal}
+		return &object.Float{Value: leftVal * rightVal}
+	case "/", "%":
+		if rightVal == 0 {
+			return newError(object.ZeroDivisionErrorKind, "division by zero: %s %s %s",
+				left.Inspect(), operator, right.Inspect())
+		}
+		if operator == "/" {
+			return &object.Float{Value: leftVal / rightVal}
+		}
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
 	case ">":
 		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
 	case "==":
 		return nativeBoolToBooleanObject(leftVal == rightVal)
 	case "!=":
 		return nativeBoolToBooleanObject(leftVal != rightVal)
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError(object.TypeErrorKind, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func isInteger(obj object.Object) bool {
+	return obj.Type() == object.IntegerObj || obj.Type() == object.BigIntObj
+}
+
+func isNumber(obj object.Object) bool {
+	return isInteger(obj) || obj.Type() == object.FloatObj
+}
+
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.BigInt:
+		f, _ := new(big.Float).SetInt(obj.Value).Float64()
+		return f
+	case *object.Float:
+		return obj.Value
+	default:
+		return 0
 	}
 }
 
-func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
+// evalStringInfixExpression 文字列を連結する。連結した文字列の大きさは連結する前に割り当て量へ加算する。
+func (e *Evaluator) evalStringInfixExpression(operator string, left, right object.Object) object.Object {
 	if operator != "+" {
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError(object.TypeErrorKind, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 
 	leftVal := left.(*object.String).Value
 	rightVal := right.(*object.String).Value
+	if err := e.reserve(stringSize(len(leftVal) + len(rightVal))); err != nil {
+		return err
+	}
 	return &object.String{Value: leftVal + rightVal}
 }
 
-func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
-	condition := Eval(ie.Condition, env)
-	if isError(condition) {
+func (e *Evaluator) evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+	condition := e.Eval(ie.Condition, env)
+	if isAbrupt(condition) {
 		return condition
 	}
 
 	if isTruthy(condition) {
-		return Eval(ie.Consequence, env)
+		return e.Eval(ie.Consequence, env)
 	} else if ie.Alternative != nil {
-		return Eval(ie.Alternative, env)
+		return e.Eval(ie.Alternative, env)
 	} else {
 		return null
 	}
 }
 
-func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+func (e *Evaluator) evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
 	if val, ok := env.Get(node.Value); ok {
 		return val
 	}
 
+	if builtin, ok := e.Builtins[node.Value]; ok {
+		return builtin
+	}
+
 	if builtin, ok := builtins[node.Value]; ok {
 		return builtin
 	}
 
-	return newError("identifier not found: " + node.Value)
+	return newError(object.NameErrorKind, "identifier not found: %s", node.Value)
 }
 
 func isTruthy(obj object.Object) bool {
@@ -286,8 +783,8 @@ func isTruthy(obj object.Object) bool {
 	}
 }
 
-func newError(format string, a ...interface{}) *object.Error {
-	return &object.Error{Message: fmt.Sprintf(format, a...)}
+func newError(kind string, format string, a ...interface{}) *object.Error {
+	return object.NewError(kind, format, a...)
 }
 
 func isError(obj object.Object) bool {
@@ -297,12 +794,27 @@ func isError(obj object.Object) bool {
 	return false
 }
 
-func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+// isAbrupt 評価結果がエラー、return、break、continue のいずれかかを返却する。
+// 式の途中でこれらが発生した場合は、残りの評価を打ち切って結果をそのまま伝播させる。
+func isAbrupt(obj object.Object) bool {
+	if obj == nil {
+		return false
+	}
+
+	switch obj.Type() {
+	case object.ErrorObj, object.ReturnValueObj, object.BreakObj, object.ContinueObj:
+		return true
+	default:
+		return false
+	}
+}
+
+func (e *Evaluator) evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
 	var result []object.Object
 
-	for _, e := range exps {
-		evaluated := Eval(e, env)
-		if isError(evaluated) {
+	for _, exp := range exps {
+		evaluated := e.Eval(exp, env)
+		if isAbrupt(evaluated) {
 			return []object.Object{evaluated}
 		}
 		result = append(result, evaluated)
@@ -311,30 +823,146 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 	return result
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// Apply 関数または組み込み関数を引数に適用し、結果を返却する。
+func (e *Evaluator) Apply(fn object.Object, args []object.Object) object.Object {
+	return e.applyFunction(fn, args)
+}
+
+// applyFunction 関数を呼び出す。
+// 関数本体が末尾呼び出しで終わった場合は、Goのスタックを消費しないよう呼び出し先をこのループ内で続けて呼び出す(trampoline)。
+// 末尾呼び出しで置き換えられた呼び出し元のフレームはスタックトレースに残らない。
+func (e *Evaluator) applyFunction(fn object.Object, args []object.Object) object.Object {
+	var call *ast.CallExpression // 直前に行った末尾呼び出し
+
+	for {
+		result := e.callFunction(fn, args)
+
+		tc, ok := result.(*tailCall)
+		if !ok {
+			if err, ok := result.(*object.Error); ok && call != nil {
+				if !err.Pos.IsValid() {
+					err.Pos = call.Pos()
+				}
+				pushStackFrame(err, call, fn)
+			}
+			return result
+		}
+
+		call, fn, args = tc.call, tc.fn, tc.args
+	}
+}
+
+// callFunction 関数を1回呼び出す。本体が末尾呼び出しで終わった場合は *tailCall を返却する。
+func (e *Evaluator) callFunction(fn object.Object, args []object.Object) object.Object {
 	switch fn := fn.(type) {
 
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
+		if e.MaxCallDepth > 0 && e.callDepth >= e.MaxCallDepth {
+			return newError(object.CallDepthErrorKind, "maximum call depth exceeded: %d", e.MaxCallDepth)
+		}
+		e.callDepth++
+		defer func() { e.callDepth-- }()
+
+		extendedEnv, err := e.extendFunctionEnv(fn, args)
+		if err != nil {
+			return err
+		}
+		evaluated := e.Eval(fn.Body, extendedEnv)
+		// let 文やループ文で終わる本体は値を持たないため null とする。
+		if evaluated == nil {
+			return null
+		}
 		return unwrapReturnValue(evaluated)
 
 	case *object.Builtin:
-		return fn.Fn(args...)
+		result := fn.Fn(args...)
+		if result == nil {
+			return null
+		}
+		if isArgumentValue(result, args) {
+			return result
+		}
+		return e.allocate(result)
+
+	default:
+		return newError(object.TypeErrorKind, "not a function: %s", fn.Type())
+	}
+}
 
+// pushStackFrame 関数呼び出しから伝播したエラーに、呼び出し元のフレームを追加する。
+// フレームの名前には関数自身の名前を優先し、無名関数の場合は呼び出しに使用した識別子とする。
+// 関数ではない値を呼び出したことによるエラーは、呼び出し自体が失敗しているため追加しない。
+func pushStackFrame(err *object.Error, call *ast.CallExpression, fn object.Object) {
+	switch fn.(type) {
+	case *object.Function, *object.Builtin:
 	default:
-		return newError("not a function: %s", fn.Type())
+		return
 	}
+
+	name := ""
+	if f, ok := fn.(*object.Function); ok && f.Name != "" {
+		name = f.Name
+	} else if ident, ok := call.Function.(*ast.Identifier); ok {
+		name = ident.Value
+	}
+
+	err.Stack = append(err.Stack, object.StackFrame{Function: name, Pos: call.Pos()})
 }
 
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+// extendFunctionEnv 引数を仮引数に束縛した関数本体の環境を生成する。
+// 省略された引数には既定値を評価して束縛し、余った引数は残余引数に配列として束縛する。
+// 既定値は先行する仮引数を束縛した環境で評価するため、既定値の中で先行する仮引数を参照できる。
+func (e *Evaluator) extendFunctionEnv(fn *object.Function, args []object.Object) (*object.Environment, *object.Error) {
+	required := len(fn.Parameters)
+	for required > 0 && fn.Defaults != nil && fn.Defaults[required-1] != nil {
+		required--
+	}
+
+	if len(args) < required || (fn.Rest == nil && len(args) > len(fn.Parameters)) {
+		return nil, newError(object.ArgumentErrorKind, "wrong number of arguments: want=%s, got=%d",
+			arityString(required, len(fn.Parameters), fn.Rest != nil), len(args))
+	}
+
 	env := object.NewEnclosedEnvironment(fn.Env)
 
 	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+		if paramIdx < len(args) {
+			env.Set(param.Value, args[paramIdx])
+			continue
+		}
+
+		val := e.Eval(fn.Defaults[paramIdx], env)
+		if err, ok := val.(*object.Error); ok {
+			return nil, err
+		}
+		env.Set(param.Value, val)
+	}
+
+	if fn.Rest != nil {
+		rest := []object.Object{}
+		if len(args) > len(fn.Parameters) {
+			rest = append(rest, args[len(fn.Parameters):]...)
+		}
+		restArray := e.allocate(&object.Array{Elements: rest})
+		if err, ok := restArray.(*object.Error); ok {
+			return nil, err
+		}
+		env.Set(fn.Rest.Value, restArray)
 	}
 
-	return env
+	return env, nil
+}
+
+// arityString 受け取ることのできる引数の数を "2"、"1 to 3"、"at least 1" の形式で返却する。
+func arityString(min, max int, variadic bool) string {
+	switch {
+	case variadic:
+		return fmt.Sprintf("at least %d", min)
+	case min == max:
+		return strconv.Itoa(min)
+	default:
+		return fmt.Sprintf("%d to %d", min, max)
+	}
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
@@ -345,14 +973,140 @@ func unwrapReturnValue(obj object.Object) object.Object {
 	return obj
 }
 
-func evalIndexExpression(left, index object.Object) object.Object {
+func (e *Evaluator) evalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ArrayObj && index.Type() == object.IntegerObj:
 		return evalArrayIndexExpression(left, index)
 	case left.Type() == object.HashObj:
 		return evalHashIndexExpression(left, index)
+	case left.Type() == object.StringObj && index.Type() == object.IntegerObj:
+		return e.allocate(evalStringIndexExpression(left, index))
+	case left.Type() == object.ExceptionObj && index.Type() == object.StringObj:
+		return evalExceptionIndexExpression(left, index)
+	case left.Type() == object.ModuleObj && index.Type() == object.StringObj:
+		return evalModuleIndexExpression(left, index)
+	default:
+		return newError(object.TypeErrorKind, "index operator not supported: %s", left.Type())
+	}
+}
+
+// evalStringIndexExpression 文字列の idx 番目の文字(バイトではなくUnicodeのコードポイント)を返却する。
+func evalStringIndexExpression(str, index object.Object) object.Object {
+	runes := []rune(str.(*object.String).Value)
+	idx := index.(*object.Integer).Value
+
+	if idx < 0 || idx >= int64(len(runes)) {
+		return null
+	}
+
+	return &object.String{Value: string(runes[idx])}
+}
+
+// evalExceptionIndexExpression 捕捉したエラーの属性(message, kind, stack, value)を返却する。
+// 存在しない属性や値を持たない属性の場合は null を返却する。
+func evalExceptionIndexExpression(exc, index object.Object) object.Object {
+	if val, ok := exc.(*object.Exception).Field(index.(*object.String).Value); ok && val != nil {
+		return val
+	}
+	return null
+}
+
+// evalAssignExpression 代入式を評価し、代入した値を返却する。
+// 識別子への代入は、その識別子を束縛している最も内側の環境の値を更新する。
+func (e *Evaluator) evalAssignExpression(node *ast.AssignExpression, env *object.Environment) object.Object {
+	// "+=" などの複合代入では、代入演算子から "=" を除いた演算子で演算した結果を代入する。
+	operator := strings.TrimSuffix(node.Operator, "=")
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		current, ok := env.Get(target.Value)
+		if !ok {
+			return newError(object.NameErrorKind, "identifier not found: %s", target.Value)
+		}
+
+		val := e.Eval(node.Value, env)
+		if isAbrupt(val) {
+			return val
+		}
+
+		if operator != "" {
+			val = e.evalInfixExpression(operator, current, val)
+			if isAbrupt(val) {
+				return val
+			}
+		}
+
+		env.Assign(target.Value, val)
+		return val
+
+	case *ast.IndexExpression:
+		left := e.Eval(target.Left, env)
+		if isAbrupt(left) {
+			return left
+		}
+		index := e.Eval(target.Index, env)
+		if isAbrupt(index) {
+			return index
+		}
+
+		var current object.Object
+		if operator != "" {
+			current = e.evalIndexExpression(left, index)
+			if isAbrupt(current) {
+				return current
+			}
+		}
+
+		val := e.Eval(node.Value, env)
+		if isAbrupt(val) {
+			return val
+		}
+
+		if operator != "" {
+			val = e.evalInfixExpression(operator, current, val)
+			if isAbrupt(val) {
+				return val
+			}
+		}
+
+		return e.evalIndexAssignment(left, index, val)
+
+	default:
+		return newError(object.TypeErrorKind, "invalid assignment target: %s", node.Target.String())
+	}
+}
+
+// evalIndexAssignment 配列の要素またはハッシュの値を更新する。
+// ハッシュにキーを追加する場合は、増えた大きさを割り当て量に加算する。
+func (e *Evaluator) evalIndexAssignment(left, index, val object.Object) object.Object {
+	switch {
+	case left.Type() == object.ArrayObj && index.Type() == object.IntegerObj:
+		arrayObject := left.(*object.Array)
+		idx := index.(*object.Integer).Value
+
+		if idx < 0 || idx >= int64(len(arrayObject.Elements)) {
+			return newError(object.IndexErrorKind, "index out of range: %d", idx)
+		}
+
+		arrayObject.Elements[idx] = val
+		return val
+	case left.Type() == object.HashObj:
+		hashObject := left.(*object.Hash)
+
+		key, ok := object.AsHashable(index)
+		if !ok {
+			return newError(object.TypeErrorKind, "unusable as hash key: %s", index.Type())
+		}
+
+		if _, exists := hashObject.Get(key); !exists {
+			if err := e.reserve(hashPairSize); err != nil {
+				return err
+			}
+		}
+		hashObject.Set(key, val)
+		return val
 	default:
-		return newError("index operator not supported: %s", left.Type())
+		return newError(object.TypeErrorKind, "index assignment not supported: %s", left.Type())
 	}
 }
 
@@ -368,41 +1122,40 @@ func evalArrayIndexExpression(array, index object.Object) object.Object {
 	return arrayObject.Elements[idx]
 }
 
-func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+func (e *Evaluator) evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+	hash := object.NewHash(len(node.Keys))
 
-	for keyNode, valueNode := range node.Pairs {
-		key := Eval(keyNode, env)
-		if isError(key) {
+	for _, keyNode := range node.Keys {
+		key := e.Eval(keyNode, env)
+		if isAbrupt(key) {
 			return key
 		}
 
-		hashKey, ok := key.(object.Hashable)
+		hashKey, ok := object.AsHashable(key)
 		if !ok {
-			return newError("unusable as hash key: %s", key.Type())
+			return newError(object.TypeErrorKind, "unusable as hash key: %s", key.Type())
 		}
 
-		value := Eval(valueNode, env)
-		if isError(value) {
+		value := e.Eval(node.Pairs[keyNode], env)
+		if isAbrupt(value) {
 			return value
 		}
 
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		hash.Set(hashKey, value)
 	}
 
-	return &object.Hash{Pairs: pairs}
+	return e.allocate(hash)
 }
 
 func evalHashIndexExpression(hash, index object.Object) object.Object {
 	hashObject := hash.(*object.Hash)
 
-	key, ok := index.(object.Hashable)
+	key, ok := object.AsHashable(index)
 	if !ok {
-		return newError("unusable as hash key: %s", index.Type())
+		return newError(object.TypeErrorKind, "unusable as hash key: %s", index.Type())
 	}
 
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	pair, ok := hashObject.Get(key)
 	if !ok {
 		return null
 	}
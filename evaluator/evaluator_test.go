@@ -27,6 +27,16 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"3 * 3 * 3 + 10", 37},
 		{"3 * (3 * 3) + 10", 37},
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"2 + 7 % 4 * 2", 8},
+		{"6 & 3", 2},
+		{"6 | 3", 7},
+		{"6 ^ 3", 5},
+		{"1 << 4", 16},
+		{"-16 >> 2", -4},
+		{"1 >> 70", 0},
+		{"1 | 2 & 3", 3},
 	}
 
 	for _, tt := range tests {
@@ -35,6 +45,31 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.5", 3.5},
+		{"-2.5", -2.5},
+		{"1.5 + 1.5", 3},
+		{"1 + 0.5", 1.5},
+		{"0.5 + 1", 1.5},
+		{"10 - 2.5", 7.5},
+		{"2 * 1.25", 2.5},
+		{"1 / 4.0", 0.25},
+		{"7.0 / 2", 3.5},
+		{"1e3 + 1", 1001},
+		{"(1 + 2) / 2.0", 1.5},
+		{"7.5 % 2", 1.5},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testFloatObject(t, evaluated, tt.expected)
+	}
+}
+
 func TestEvalBooleanExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -59,6 +94,24 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"(1 < 2) == false", false},
 		{"(1 > 2) == true", false},
 		{"(1 > 2) == false", true},
+		{"1.5 < 2", true},
+		{"2 > 1.5", true},
+		{"1.0 == 1", true},
+		{"1 != 1.0", false},
+		{"0.1 + 0.2 == 0.3", false},
+		{"1 <= 1", true},
+		{"2 <= 1", false},
+		{"1 >= 1", true},
+		{"1 >= 2", false},
+		{"1.5 >= 1", true},
+		{"100000000000000000000 >= 1", true},
+		{"true && true", true},
+		{"true && false", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 && 0", true},
+		{"1 < 2 && 2 < 3", true},
+		{"1 > 2 || 2 > 3", false},
 	}
 
 	for _, tt := range tests {
@@ -177,6 +230,10 @@ func TestErrorHandling(t *testing.T) {
 			"-true",
 			"unknown operator: -BOOLEAN",
 		},
+		{
+			"1.5 + true",
+			"type mismatch: FLOAT + BOOLEAN",
+		},
 		{
 			"true + false;",
 			"unknown operator: BOOLEAN + BOOLEAN",
@@ -213,6 +270,130 @@ if (10 > 1) {
 			"foobar",
 			"identifier not found: foobar",
 		},
+		{
+			"1 / 0",
+			"division by zero: 1 / 0",
+		},
+		{
+			"let f = fn(x) { 10 / x }; f(0) + 1;",
+			"division by zero: 10 / 0",
+		},
+		{
+			"x = 5",
+			"identifier not found: x",
+		},
+		{
+			"let f = fn() { let y = 1; }; f(); y += 1;",
+			"identifier not found: y",
+		},
+		{
+			"let x = true; x += 1;",
+			"type mismatch: BOOLEAN + INTEGER",
+		},
+		{
+			"let arr = [1, 2]; arr[2] = 3;",
+			"index out of range: 2",
+		},
+		{
+			`let h = {}; h[fn(x) { x }] = 1;`,
+			"unusable as hash key: FUNCTION",
+		},
+		{
+			`{[1, {}]: 1}`,
+			"unusable as hash key: ARRAY",
+		},
+		{
+			`let a = [1]; a[0] = a; let h = {}; h[a] = 1;`,
+			"unusable as hash key: ARRAY",
+		},
+		{
+			`let a = [1]; a[0] = a; {[a]: 1}`,
+			"unusable as hash key: ARRAY",
+		},
+		{
+			`{"a": 1}[[fn() {}]]`,
+			"unusable as hash key: ARRAY",
+		},
+		{
+			`let s = "abc"; s[0] = "x";`,
+			"index assignment not supported: STRING",
+		},
+		{
+			"for (x in 5) { x }",
+			"not iterable: INTEGER",
+		},
+		{
+			"5 % 0",
+			"division by zero: 5 % 0",
+		},
+		{
+			"100000000000000000000 % 0",
+			"division by zero: 100000000000000000000 % 0",
+		},
+		{
+			"1.5 / 0",
+			"division by zero: 1.5 / 0",
+		},
+		{
+			"1 / 0.0",
+			"division by zero: 1 / 0.0",
+		},
+		{
+			"5.5 % -0.0",
+			"division by zero: 5.5 % -0.0",
+		},
+		{
+			"1 << -1",
+			"negative shift count: -1",
+		},
+		{
+			"1 >> -1",
+			"negative shift count: -1",
+		},
+		{
+			"1 << 100000000000000000000",
+			"shift count too large: 100000000000000000000",
+		},
+		{
+			"1.5 & 1",
+			"unknown operator: FLOAT & INTEGER",
+		},
+		{
+			"true && 1 + true",
+			"type mismatch: INTEGER + BOOLEAN",
+		},
+		{
+			`let h = null; h["a"]`,
+			"index operator not supported: NULL",
+		},
+		{
+			"null ?? 1 + true",
+			"type mismatch: INTEGER + BOOLEAN",
+		},
+		{
+			"let i = 0; while (i < 3) { i += 1; if (i == 2) { i + true; } }",
+			"type mismatch: INTEGER + BOOLEAN",
+		},
+		{
+			"let f = fn(a, b) { a }; f(1);",
+			"wrong number of arguments: want=2, got=1",
+		},
+		{
+			"let f = fn(a) { a }; f(1, 2);",
+			"wrong number of arguments: want=1, got=2",
+		},
+		{
+			"let f = fn(a, b = 2) { a }; f();",
+			"wrong number of arguments: want=1 to 2, got=0",
+		},
+		{
+			"let f = fn(a, ...rest) { a }; f();",
+			"wrong number of arguments: want=at least 1, got=0",
+		},
+		{
+			"let f = fn(a, b = a + true) { a }; f(1);",
+			"type mismatch: INTEGER + BOOLEAN",
+		},
 	}
 
 	for _, tt := range tests {
@@ -230,6 +411,358 @@ if (10 > 1) {
 	}
 }
 
+func TestTryExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"try { 1 } catch (e) { 2 }", 1},
+		{"try { 1 + true; 2 } catch (e) { 3 }", 3},
+		{`try { throw "boom"; } catch (e) { e["message"] }`, "boom"},
+		{`try { throw "boom"; } catch (e) { e["kind"] }`, "Error"},
+		{`try { throw {"code": 42}; } catch (e) { e["value"]["code"] }`, 42},
+		{`try { 1 / 0 } catch (e) { e["kind"] }`, "ZeroDivisionError"},
+		{`try { 1 + true } catch (e) { e["kind"] }`, "TypeError"},
+		{`try { x } catch (e) { e["kind"] }`, "NameError"},
+		{`try { len(1, 2) } catch (e) { e["kind"] }`, "ArgumentError"},
+		{`try { len(1) } catch (e) { e["message"] }`, "argument to `len` not supported, got INTEGER"},
+		{`try { 1 / 0 } catch (e) { e["value"] }`, nil},
+		{`try { 1 / 0 } catch (e) { e["unknown"] }`, nil},
+		{`try { 1 / 0 } catch (e) { len(e["stack"]) }`, 0},
+		{`let f = fn() { 1 / 0 }; try { f() } catch (e) { e["stack"][0] }`, "f (1:32)"},
+		{`try { try { throw "inner"; } catch (e) { throw e; } } catch (e) { e["message"] }`, "inner"},
+		{`try { try { throw "inner"; } finally { 1 } } catch (e) { e["message"] }`, "inner"},
+		{`let f = fn() { throw "in f"; }; try { f(); 1 } catch (e) { e["message"] }`, "in f"},
+		{"let x = 0; try { x = 1; } finally { x += 10; } x;", 11},
+		{"let x = 0; try { 1 / 0 } catch (e) { x = 1; } finally { x += 10; } x;", 11},
+		{"try { 1 } finally { 2 }", 1},
+		{"let f = fn() { try { return 1; } finally { return 2; } }; f();", 2},
+		{"let f = fn() { try { return 1; } catch (e) { 0 } }; f();", 1},
+		{"let s = 0; for (x in [1, 2, 3]) { try { if (x == 2) { throw x; } s += x; } catch (e) { s += 10 * e[\"value\"]; } } s;", 24},
+		{"let s = 0; for (x in [1, 2, 3]) { try { if (x == 2) { break; } } finally { s += x; } } s;", 3},
+		{"try { throw 1; } catch { 5 }", 5},
+		{"let e = 1; try { throw 2; } catch (e) { e } e;", 1},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok {
+				t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if str.Value != expected {
+				t.Errorf("String has wrong value. got=%q, want=%q", str.Value, expected)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestThrowErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedKind    string
+		expectedMessage string
+	}{
+		{`throw "boom";`, object.ErrorKind, "boom"},
+		{"throw [1, 2];", object.ErrorKind, "[1, 2]"},
+		{"throw 1 + true;", object.TypeErrorKind, "type mismatch: INTEGER + BOOLEAN"},
+		{`try { 1 / 0 } catch (e) { throw e; }`, object.ZeroDivisionErrorKind, "division by zero: 1 / 0"},
+		{"1.0 % 0", object.ZeroDivisionErrorKind, "division by zero: 1.0 % 0"},
+		{`try { throw "a"; } catch (e) { throw "b"; }`, object.ErrorKind, "b"},
+		{`try { 1 } finally { throw "f"; }`, object.ErrorKind, "f"},
+		{`try { throw "a"; } finally { 1 }`, object.ErrorKind, "a"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Kind != tt.expectedKind {
+			t.Errorf("wrong error kind. expected=%q, got=%q", tt.expectedKind, errObj.Kind)
+		}
+
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestStackTrace(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"foo", []string{}},
+		{"let f = fn() {\n  foo\n};\nf();", []string{"f (main.monkey:4:2)"}},
+		{
+			"let inner = fn(x) { x + true };\nlet outer = fn() {\n  inner(1)\n};\nouter();",
+			[]string{"inner (main.monkey:3:8)", "outer (main.monkey:5:6)"},
+		},
+		{"fn() { foo }();", []string{"<anonymous> (main.monkey:1:13)"}},
+		{"let h = {\"f\": fn() { foo }};\nh[\"f\"]();", []string{"<anonymous> (main.monkey:2:7)"}},
+		{"len(1);", []string{"len (main.monkey:1:4)"}},
+		{"let f = fn() { len(1) };\nf();", []string{"len (main.monkey:1:19)", "f (main.monkey:2:2)"}},
+		{
+			"let h = fn() { foo };\nlet g = fn() { h() };\nlet f = fn() { g() };\nf();",
+			[]string{"h (main.monkey:2:17)", "f (main.monkey:4:2)"},
+		},
+		{"let f = fn() { 1() };\nf();", []string{"f (main.monkey:2:2)"}},
+		{"fn f() { foo }\nlet g = f;\ng();", []string{"f (main.monkey:3:2)"}},
+		{"let x = 1; x();", []string{}},
+		{
+			"let f = fn() { throw \"boom\"; };\nlet g = fn() { try { f() } catch (e) { throw e; } };\ng();",
+			[]string{"f (main.monkey:2:23)", "g (main.monkey:3:2)"},
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewWithFilename("main.monkey", tt.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+		env := object.NewEnvironment()
+		evaluated := Eval(program, env)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if len(errObj.Stack) != len(tt.expected) {
+			t.Errorf("wrong stack length for %q. expected=%d, got=%d (%v)", tt.input, len(tt.expected), len(errObj.Stack), errObj.Stack)
+			continue
+		}
+
+		for i, frame := range errObj.Stack {
+			if frame.String() != tt.expected[i] {
+				t.Errorf("wrong frame %d. expected=%q, got=%q", i, tt.expected[i], frame.String())
+			}
+		}
+	}
+}
+
+func TestEvalBigIntExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775808", "9223372036854775808"},
+		{"-9223372036854775808", "-9223372036854775808"},
+		{"100000000000000000000 - 99999999999999999999", "1"},
+		{"100000000000000000000 / 10", "10000000000000000000"},
+		{"100000000000000000000 / 100", "1000000000000000000"},
+		{"9223372036854775807 * 9223372036854775807", "85070591730234615847396907784232501249"},
+		{"-(100000000000000000000)", "-100000000000000000000"},
+		{"let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(25)", "15511210043330985984000000"},
+		{"100000000000000000000 > 1", "true"},
+		{"1 < 100000000000000000000", "true"},
+		{"100000000000000000000 == 100000000000000000000", "true"},
+		{"100000000000000000000 != 100000000000000000001", "true"},
+		{"100000000000000000000 * 0.5", "5e+19"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	integer, ok := testEval("100000000000000000000 - 99999999999999999999").(*object.Integer)
+	if !ok || integer.Value != 1 {
+		t.Errorf("result is not demoted to Integer. got=%T (%+v)", integer, integer)
+	}
+}
+
+func TestLogicalShortCircuit(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"false && undefinedName", false},
+		{"true || undefinedName", true},
+		{"let n = 0; let f = fn() { n += 1; true }; false && f(); n == 0", true},
+		{"let n = 0; let f = fn() { n += 1; true }; true && f(); n == 1", true},
+		{"let n = 0; let f = fn() { n += 1; true }; true || f(); n == 0", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestNullExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"null", nil},
+		{"null == null", true},
+		{"let x = null; x != null", false},
+		{"[1][5] == null", true},
+		{"!null", true},
+		{"null ?? 5", 5},
+		{"3 ?? 5", 3},
+		{"false ?? 5", false},
+		{"null ?? null ?? 7", 7},
+		{"1 ?? undefinedName", 1},
+		{`let h = {"a": {"b": 2}}; h?["a"]?["b"]`, 2},
+		{`let h = {"a": {"b": 2}}; h?["x"]?["b"]`, nil},
+		{`let h = null; h?["a"]?["b"]`, nil},
+		{`let h = null; h?[undefinedName]`, nil},
+		{`let h = {}; h?["a"] ?? "default"`, "default"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok {
+				t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if str.Value != expected {
+				t.Errorf("String has wrong value. got=%q, want=%q", str.Value, expected)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestIntegerOverflow(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedPromoted string
+		expectedMessage  string
+	}{
+		{"9223372036854775807 + 1", "9223372036854775808", "integer overflow: 9223372036854775807 + 1"},
+		{"-9223372036854775807 - 2", "-9223372036854775809", "integer overflow: -9223372036854775807 - 2"},
+		{"4611686018427387904 * 2", "9223372036854775808", "integer overflow: 4611686018427387904 * 2"},
+		{"-9223372036854775807 - 1 - 0 * -1", "-9223372036854775808", ""},
+		{"let min = -9223372036854775807 - 1; min * -1", "9223372036854775808", "integer overflow: -9223372036854775808 * -1"},
+		{"let min = -9223372036854775807 - 1; min / -1", "9223372036854775808", "integer overflow: -9223372036854775808 / -1"},
+		{"let min = -9223372036854775807 - 1; -min", "9223372036854775808", "integer overflow: -(-9223372036854775808)"},
+		{"3037000499 * 3037000499", "9223372030926249001", ""},
+		{"-4611686018427387904 * 2", "-9223372036854775808", ""},
+		{"1 << 63", "9223372036854775808", "integer overflow: 1 << 63"},
+		{"-1 << 63", "-9223372036854775808", ""},
+		{"3 << 100", "3802951800684688204490109616128", "integer overflow: 3 << 100"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expectedPromoted {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expectedPromoted, evaluated.Inspect())
+		}
+
+		l := lexer.New(tt.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+
+		e := New()
+		e.CheckedArithmetic = true
+		evaluated = e.Eval(program, object.NewEnvironment())
+
+		if tt.expectedMessage == "" {
+			if evaluated.Inspect() != tt.expectedPromoted {
+				t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expectedPromoted, evaluated.Inspect())
+			}
+			continue
+		}
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedPos     string
+		expectedInspect string
+	}{
+		{
+			"5 + true;",
+			"main.monkey:1:3",
+			"ERROR: main.monkey:1:3: type mismatch: INTEGER + BOOLEAN",
+		},
+		{
+			"let x = 1;\n  -true",
+			"main.monkey:2:3",
+			"ERROR: main.monkey:2:3: unknown operator: -BOOLEAN",
+		},
+		{
+			"let f = fn() {\n  foobar;\n};\nf();",
+			"main.monkey:2:3",
+			"ERROR: main.monkey:2:3: identifier not found: foobar",
+		},
+		{
+			"let x = [1];\nlen(x, x)",
+			"main.monkey:2:4",
+			"ERROR: main.monkey:2:4: wrong number of arguments. got=2, want=1",
+		},
+		{
+			"let x = 1;\n  throw \"boom\";",
+			"main.monkey:2:3",
+			"ERROR: main.monkey:2:3: boom",
+		},
+		{
+			"try {\n  1 / 0\n} catch (e) {\n  throw e;\n}",
+			"main.monkey:2:5",
+			"ERROR: main.monkey:2:5: division by zero: 1 / 0",
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewWithFilename("main.monkey", tt.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+		evaluated := Eval(program, object.NewEnvironment())
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Pos.String() != tt.expectedPos {
+			t.Errorf("wrong error position. expected=%q, got=%q", tt.expectedPos, errObj.Pos.String())
+		}
+
+		if errObj.Inspect() != tt.expectedInspect {
+			t.Errorf("wrong inspect. expected=%q, got=%q", tt.expectedInspect, errObj.Inspect())
+		}
+	}
+}
+
 func TestLetStatements(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -239,6 +772,7 @@ func TestLetStatements(t *testing.T) {
 		{"let a = 5 * 5; a;", 25},
 		{"let a = 5; let b = a; b;", 5},
 		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+		{"let 合計 = 10; let 値2 = 合計 * 2; 値2;", 20},
 	}
 
 	for _, tt := range tests {
@@ -246,6 +780,93 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let a = 5; a = 10; a;", 10},
+		{"let a = 5; a = a + 1;", 6},
+		{"let a = 5; let b = 0; a = b = 3; a + b;", 6},
+		{"let a = 5; a += 2; a;", 7},
+		{"let a = 5; a -= 2; a;", 3},
+		{"let a = 5; a *= 2; a;", 10},
+		{"let a = 5; a /= 2; a;", 2},
+		{"let a = 1.5; a *= 2; a;", 3.0},
+		{"let a = 9223372036854775807; a += 1; a > 0;", true},
+		{"let a = 1; let f = fn() { a = 2; }; f(); a;", 2},
+		{"let a = 1; let f = fn() { let a = 5; a = 2; }; f(); a;", 1},
+		{"let counter = fn() { let n = 0; fn() { n += 1 } }; let c = counter(); c(); c(); c();", 3},
+		{"let arr = [1, 2, 3]; arr[0] = 10; arr[0] + arr[2];", 13},
+		{"let arr = [1, 2, 3]; arr[1] *= 5; arr[1];", 10},
+		{`let h = {"a": 1}; h["a"] += 1; h["a"];`, 2},
+		{`let h = {}; h["b"] = 7; h["b"];`, 7},
+		{"let h = {}; h[true] = 1; h[true];", 1},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestLoopStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let i = 0; while (i < 5) { i += 1; } i;", 5},
+		{"let i = 0; while (false) { i += 1; } i;", 0},
+		{"let i = 0; while (true) { i += 1; if (i == 3) { break; } } i;", 3},
+		{"let i = 0; let s = 0; while (i < 5) { i += 1; if (i == 2) { continue; } s += i; } s;", 13},
+		{"let s = 0; for (x in [1, 2, 3]) { s += x; } s;", 6},
+		{"let s = 0; for (x in []) { s += 1; } s;", 0},
+		{`let s = 0; for (k in {"a": 1, "b": 2}) { s += 1; } s;`, 2},
+		{`let s = ""; for (k in {"c": 1, "a": 2, "b": 3}) { s = s + k; } s;`, "cab"},
+		{`let h = {"c": 1}; h["a"] = 2; h["c"] = 3; let s = ""; for (k in h) { s = s + k; } s;`, "ca"},
+		{`let s = ""; for (c in "abc") { s = c + s; } s;`, "cba"},
+		{"let s = 0; for (x in [1, 2, 3, 4]) { if (x == 2) { continue; } if (x == 4) { break; } s += x; } s;", 4},
+		{"let s = 0; for (x in [1, 2]) { for (y in [10, 20]) { if (y == 20) { break; } s += x * y; } } s;", 30},
+		{"let f = fn() { let i = 0; while (true) { i += 1; if (i == 4) { return i * 10; } } }; f();", 40},
+		{"let f = fn() { for (x in [1, 2]) { } }; f();", nil},
+		{"let fs = []; for (x in [1, 2]) { fs = push(fs, fn() { x }); } fs[0]() + fs[1]();", 3},
+		// 式の中の break と continue は値にならず、ループまで伝播する。
+		{"let i = 0; while (true) { i += 1; let x = if (i > 2) { break } else { 1 }; } i;", 3},
+		{"let i = 0; while (i < 5) { i += 1; first(if (i > 2) { break } else { [i] }); } i;", 3},
+		{"let s = 0; for (x in [1, 2, 3]) { s += if (x == 2) { continue } else { x }; } s;", 4},
+		{"let s = []; for (x in [1, 2, 3]) { s = push(s, [x, if (x == 2) { break }]); } len(s);", 1},
+		{"let f = fn() { let x = if (true) { return 5 }; 10 }; f();", 5},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok {
+				t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if str.Value != expected {
+				t.Errorf("String has wrong value. got=%q, want=%q", str.Value, expected)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
 func TestFunctionObject(t *testing.T) {
 	input := "fn(x) { x + 2; };"
 
@@ -270,6 +891,56 @@ func TestFunctionObject(t *testing.T) {
 	}
 }
 
+func TestFunctionStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"fn add(x, y) { x + y } add(1, 2);", 3},
+		{"let r = add(1, 2); fn add(x, y) { x + y } r;", 3},
+		{"fn fact(n) { if (n == 0) { 1 } else { n * fact(n - 1) } } fact(5);", 120},
+		{`fn isEven(n) { if (n == 0) { true } else { isOdd(n - 1) } }
+fn isOdd(n) { if (n == 0) { false } else { isEven(n - 1) } }
+isEven(10);`, true},
+		{"fn outer() { return inner() * 2; fn inner() { 21 } } outer();", 42},
+		{"fn f() { 1 } let g = f; fn h() { } g() + 1;", 2},
+		{"fn f() { fn g() { 1 } } f();", nil},
+		{"if (true) { fn f() { 5 } } f();", 5},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestFunctionInspect(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(x) { x }", "fn(x) {\nx\n}"},
+		{"let f = fn(x) { x }; f", "fn f(x) {\nx\n}"},
+		{"fn g(x, y = 2) { x } g", "fn g(x, y = 2) {\nx\n}"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong Inspect. expected=%q, got=%q", tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
 func TestFunctionApplication(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -288,6 +959,44 @@ func TestFunctionApplication(t *testing.T) {
 	}
 }
 
+func TestFunctionParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let add = fn(a, b = 2) { a + b }; add(1);", 3},
+		{"let add = fn(a, b = 2) { a + b }; add(1, 5);", 6},
+		{"let f = fn(a, b = a * 10) { b }; f(3);", 30},
+		{"let n = 0; let f = fn(a = n += 1) { a }; f(); f(); n;", 2},
+		{"let f = fn(...rest) { len(rest) }; f();", 0},
+		{"let f = fn(a, ...rest) { rest }; f(1, 2, 3);", []int64{2, 3}},
+		{"let f = fn(a, b = 2, ...rest) { [a, b, len(rest)] }; f(1);", []int64{1, 2, 0}},
+		{"let f = fn(a, b = 2, ...rest) { [a, b, len(rest)] }; f(1, 5, 6, 7);", []int64{1, 5, 2}},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case []int64:
+			arr, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if len(arr.Elements) != len(expected) {
+				t.Errorf("wrong num of elements. want=%d, got=%d", len(expected), len(arr.Elements))
+				continue
+			}
+			for i, v := range expected {
+				testIntegerObject(t, arr.Elements[i], v)
+			}
+		}
+	}
+}
+
 func TestEnclosingEnvironments(t *testing.T) {
 	input := `
 let first = 10;
@@ -317,6 +1026,36 @@ addTwo(2);`
 	testIntegerObject(t, testEval(input), 4)
 }
 
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let sum = fn(n, acc) { if (n == 0) { acc } else { sum(n - 1, acc + n) } }; sum(100000, 0);", 5000050000},
+		{"let count = fn(n) { if (n == 0) { return 0; } return count(n - 1); }; count(100000);", 0},
+		{`let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } };
+let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } };
+even(100000);`, true},
+		{"let f = fn(n) { while (true) { return if (n == 0) { 1 } else { f(n - 1) }; } }; f(100000);", 1},
+		{"let f = fn(xs) { len(xs) }; f([1, 2]);", 2},
+		{"let f = fn() { g() }; let g = fn() { 7 }; f() + 1;", 8},
+		{"let f = fn() { }; let g = fn() { f() }; g();", nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
 func TestStringLiteral(t *testing.T) {
 	input := `"Hello World!"`
 
@@ -362,26 +1101,82 @@ func TestHashLiterals(t *testing.T) {
 		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
 	}
 
-	expected := map[object.HashKey]int64{
-		(&object.String{Value: "one"}).HashKey():   1,
-		(&object.String{Value: "two"}).HashKey():   2,
-		(&object.String{Value: "three"}).HashKey(): 3,
-		(&object.Integer{Value: 4}).HashKey():      4,
-		trueObj.HashKey():                          5,
-		falseObj.HashKey():                         6,
+	// 組はソースコードでの出現順に並ぶ。
+	expected := []struct {
+		key   object.Hashable
+		value int64
+	}{
+		{&object.String{Value: "one"}, 1},
+		{&object.String{Value: "two"}, 2},
+		{&object.String{Value: "three"}, 3},
+		{&object.Integer{Value: 4}, 4},
+		{trueObj, 5},
+		{falseObj, 6},
 	}
 
-	if len(result.Pairs) != len(expected) {
-		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+	if result.Len() != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", result.Len())
 	}
 
-	for expectedKey, expectedValue := range expected {
-		pair, ok := result.Pairs[expectedKey]
-		if !ok {
-			t.Errorf("no pair for given key in Pairs")
+	for i, pair := range result.Pairs() {
+		if pair.Key.Inspect() != expected[i].key.Inspect() {
+			t.Errorf("pair %d has wrong key. expected=%s, got=%s", i, expected[i].key.Inspect(), pair.Key.Inspect())
 		}
+		testIntegerObject(t, pair.Value, expected[i].value)
 
-		testIntegerObject(t, pair.Value, expectedValue)
+		if _, ok := result.Get(expected[i].key); !ok {
+			t.Errorf("no pair for given key %s", expected[i].key.Inspect())
+		}
+	}
+}
+
+func TestHashEquality(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`{"b": 1, "a": 2} == {"b": 1, "a": 2}`, true},
+		{`{"b": 1, "a": 2} != {"b": 1, "a": 2}`, false},
+		{`{"b": 1, "a": 2} == {"a": 2, "b": 1}`, false},
+		{`{"b": 1, "a": 2} != {"a": 2, "b": 1}`, true},
+		{`{} == {}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`{"a": 1} == {"a": 1, "b": 2}`, false},
+		{`{"a": [1, {"x": 1.0}]} == {"a": [1, {"x": 1}]}`, true},
+		{`{1: "a"} == {1.0: "a"}`, true},
+		{`let h = {"a": 1}; h == h`, true},
+		{`let h = {"a": 1}; let g = {"a": 1}; g["a"] = 2; h == g`, false},
+		{`let h = {}; h["b"] = 1; h["a"] = 2; h == {"b": 1, "a": 2}`, true},
+		{`let f = fn() {}; {"f": f} == {"f": f}`, true},
+		{`{"f": fn() {}} == {"f": fn() {}}`, false},
+		{`{"a": 1} == [1]`, false},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if !testBooleanObject(t, evaluated, tt.expected) {
+			t.Errorf("input: %q", tt.input)
+		}
+	}
+}
+
+func TestHashInspect(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{}`, "{}"},
+		{`{"b": 1, "a": 2, "c": 3}`, "{b: 1, a: 2, c: 3}"},
+		{`{3: "x", 1: "y", 2: "z"}`, "{3: x, 1: y, 2: z}"},
+		{`let h = {"b": 1}; h["a"] = 2; h["b"] = 3; h`, "{b: 3, a: 2}"},
+		{`{"a": 1, "a": 2}`, "{a: 2}"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%q: wrong Inspect. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
 	}
 }
 
@@ -418,6 +1213,62 @@ func TestHashIndexExpressions(t *testing.T) {
 			`{false: 5}[false]`,
 			5,
 		},
+		{
+			`{1.5: 5}[1.5]`,
+			5,
+		},
+		{
+			`{1.0: 5}[1]`,
+			5,
+		},
+		{
+			`{1: 5}[1.0]`,
+			5,
+		},
+		{
+			`{1: 5}[1.5]`,
+			nil,
+		},
+		{
+			`{100000000000000000000: 5}[1e20]`,
+			5,
+		},
+		{
+			`let h = {1: 1}; h[1.0] = 5; len(keys(h)) * h[1]`,
+			5,
+		},
+		{
+			`{[1, "a"]: 5}[[1, "a"]]`,
+			5,
+		},
+		{
+			`{[1, [true]]: 5}[[1, [true]]]`,
+			5,
+		},
+		{
+			`{[1, 2]: 5}[[2, 1]]`,
+			nil,
+		},
+		{
+			`let k = [1]; let h = {}; h[k] = 5; k[0] = 2; h[[1]]`,
+			5,
+		},
+		{
+			`let h = {}; h[[1, 2]] = 1; h[[1, 2]] = 5; len(keys(h)) * h[[1, 2]]`,
+			5,
+		},
+		{
+			`let h = {[1]: 5}; let k = keys(h)[0]; k[0] = 2; h[[1]]`,
+			5,
+		},
+		{
+			`let h = {[1]: 5}; let k = keys(h)[0]; k[0] = 2; h[[2]]`,
+			nil,
+		},
+		{
+			`let h = {[1]: 5}; for (k in h) { k[0] = 2 } h[[1]]`,
+			5,
+		},
 	}
 
 	for _, tt := range tests {
@@ -439,6 +1290,8 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("")`, 0},
 		{`len("four")`, 4},
 		{`len("hello world")`, 11},
+		{`len("日本語")`, 3},
+		{`len("\u{1F600}!")`, 2},
 		{`len(1)`, "argument to `len` not supported, got INTEGER"},
 		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
 		{`len([1, 2, 3])`, 3},
@@ -454,6 +1307,11 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`rest([])`, nil},
 		{`push([], 1)`, []int{1}},
 		{`push(1, 1)`, "argument to `push` must be ARRAY, got INTEGER"},
+		{`keys({3: 1, 1: 2, 2: 3})`, []int{3, 1, 2}},
+		{`values({3: 1, 1: 2, 2: 3})`, []int{1, 2, 3}},
+		{`keys({})`, []int{}},
+		{`keys([])`, "argument to `keys` must be HASH, got ARRAY"},
+		{`values({}, {})`, "wrong number of arguments. got=2, want=1"},
 	}
 
 	for _, tt := range tests {
@@ -552,6 +1410,38 @@ func TestArrayIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestStringIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"abc"[0]`, "a"},
+		{`"abc"[2]`, "c"},
+		{`"こんにちは"[1]`, "ん"},
+		{`let s = "日本語"; s[len(s) - 1]`, "語"},
+		{`"abc"[3]`, nil},
+		{`"abc"[-1]`, nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		expected, ok := tt.expected.(string)
+		if !ok {
+			testNullObject(t, evaluated)
+			continue
+		}
+
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if str.Value != expected {
+			t.Errorf("String has wrong value. got=%q, want=%q", str.Value, expected)
+		}
+	}
+}
+
 func testEval(input string) object.Object {
 	l := lexer.New(input)
 	p := parser.New(l)
@@ -575,6 +1465,20 @@ func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	return true
 }
 
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%g, want=%g", result.Value, expected)
+		return false
+	}
+
+	return true
+}
+
 func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
 	result, ok := obj.(*object.Boolean)
 	if !ok {
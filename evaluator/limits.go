@@ -0,0 +1,176 @@
+package evaluator
+
+import (
+	"context"
+	"monkey/ast"
+	"monkey/object"
+)
+
+// DefaultMaxCallDepth New で生成した評価器の関数呼び出しの入れ子の深さの上限。
+// 上限がない場合、深い再帰はGoのスタックを使い果たしてプロセスを異常終了させる。
+const DefaultMaxCallDepth = 10000
+
+// 割り当て量の計算に使用する値の大きさの概算(バイト数)
+const (
+	stringHeaderSize = 16 // 文字列のヘッダ
+	arrayHeaderSize  = 24 // スライスのヘッダ
+	arrayElemSize    = 16 // 配列の要素(インタフェース値)
+	hashHeaderSize   = 48 // マップのヘッダ
+	hashPairSize     = 64 // ハッシュのキーと値の組(マップのバケットの領域を含む)
+	bigIntHeaderSize = 32 // 多倍長整数のヘッダ
+)
+
+// contextCheckInterval コンテキストの取り消しを確認する間隔(評価するノード数)
+const contextCheckInterval = 256
+
+// EvalContext コンテキストが取り消されるか期限を過ぎた場合に評価を中断するようにして、Nodeの評価を行う。
+// 評価したノード数と割り当て量は呼び出しごとに数え直す。ただし、組み込み関数の中から再入した呼び出しでは
+// 外側の評価の分に加算し、外側のコンテキストによる中断も引き続き行う。
+// 中断した場合や MaxSteps、MaxCallDepth、MaxAlloc を超えた場合は、それぞれ種別の異なるエラーを返却する。
+// これらのエラーは try の catch で捕捉できない。
+func (e *Evaluator) EvalContext(ctx context.Context, node ast.Node, env *object.Environment) object.Object {
+	if err := e.begin(ctx); err != nil {
+		return err
+	}
+	defer e.end()
+
+	return e.Eval(node, env)
+}
+
+// ApplyContext EvalContext と同様に実行を制限して、関数または組み込み関数を引数に適用する。
+func (e *Evaluator) ApplyContext(ctx context.Context, fn object.Object, args []object.Object) object.Object {
+	if err := e.begin(ctx); err != nil {
+		return err
+	}
+	defer e.end()
+
+	return e.Apply(fn, args)
+}
+
+// begin 評価の中断に使用するコンテキストを追加する。最も外側の呼び出しでは評価したノード数と割り当て量を数え直す。
+// コンテキストが既に取り消されている場合は、何も変更せずにエラーを返却する。
+func (e *Evaluator) begin(ctx context.Context) *object.Error {
+	if err := ctx.Err(); err != nil {
+		return newError(object.CanceledErrorKind, "execution canceled: %s", err)
+	}
+
+	if len(e.contexts) == 0 {
+		e.steps = 0
+		e.allocated = 0
+	}
+	e.contexts = append(e.contexts, ctx)
+	return nil
+}
+
+// end begin で追加したコンテキストを取り除く。
+func (e *Evaluator) end() {
+	e.contexts[len(e.contexts)-1] = nil
+	e.contexts = e.contexts[:len(e.contexts)-1]
+}
+
+// step 評価したノード数を数え、実行を続けられない場合はエラーを返却する。
+func (e *Evaluator) step() *object.Error {
+	e.steps++
+
+	if e.MaxSteps > 0 && e.steps > e.MaxSteps {
+		return newError(object.StepLimitErrorKind, "step limit exceeded: %d", e.MaxSteps)
+	}
+
+	if len(e.contexts) > 0 && e.steps%contextCheckInterval == 0 {
+		for _, ctx := range e.contexts {
+			if err := ctx.Err(); err != nil {
+				return newError(object.CanceledErrorKind, "execution canceled: %s", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// isLimitError 実行制限による中断を表すエラーかを返却する。
+func isLimitError(err *object.Error) bool {
+	switch err.Kind {
+	case object.CanceledErrorKind, object.StepLimitErrorKind, object.CallDepthErrorKind, object.MemoryLimitErrorKind:
+		return true
+	default:
+		return false
+	}
+}
+
+// allocate 生成した値の大きさを割り当て量に加算し、値を返却する。
+// 割り当て量が MaxAlloc を超えた場合はエラーを返却する。配列、ハッシュ、文字列、多倍長整数以外の値はそのまま返却する。
+func (e *Evaluator) allocate(obj object.Object) object.Object {
+	var size int
+
+	switch obj := obj.(type) {
+	case *object.String:
+		size = stringSize(len(obj.Value))
+	case *object.Array:
+		size = arrayHeaderSize + arrayElemSize*len(obj.Elements)
+	case *object.Hash:
+		size = hashHeaderSize + hashPairSize*obj.Len()
+	case *object.BigInt:
+		size = bigIntSize(obj.Value.BitLen())
+	default:
+		return obj
+	}
+
+	if err := e.reserve(size); err != nil {
+		return err
+	}
+	return obj
+}
+
+// reserve 割り当て量に size を加算し、MaxAlloc を超えた場合はエラーを返却する。
+func (e *Evaluator) reserve(size int) *object.Error {
+	if e.MaxAlloc <= 0 {
+		return nil
+	}
+
+	e.allocated += size
+	if e.allocated > e.MaxAlloc {
+		return newError(object.MemoryLimitErrorKind, "memory limit exceeded: %d bytes", e.MaxAlloc)
+	}
+	return nil
+}
+
+// stringSize 長さ n バイトの文字列の大きさを返却する。
+func stringSize(n int) int {
+	return stringHeaderSize + n
+}
+
+// bigIntSize bits ビットの多倍長整数の大きさを返却する。
+func bigIntSize(bits int) int {
+	return bigIntHeaderSize + (bits+7)/8
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// isArgumentValue 組み込み関数の戻り値が、引数または引数の配列の要素そのものかを返却する。
+// 新たに生成されていない値を割り当て量に重複して加算しないために使用する。
+func isArgumentValue(result object.Object, args []object.Object) bool {
+	switch result.(type) {
+	case *object.String, *object.Array, *object.Hash:
+	default:
+		return false
+	}
+
+	for _, arg := range args {
+		if arg == result {
+			return true
+		}
+		if arr, ok := arg.(*object.Array); ok {
+			for _, el := range arr.Elements {
+				if el == result {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,158 @@
+package evaluator
+
+import (
+	"context"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecutionLimits(t *testing.T) {
+	tests := []struct {
+		input        string
+		maxSteps     int
+		maxCallDepth int
+		kind         string
+		message      string
+	}{
+		{"while (true) {}", 1000, 0, object.StepLimitErrorKind, "step limit exceeded: 1000"},
+		{"let f = fn() { f() }; f()", 1000, 0, object.StepLimitErrorKind, "step limit exceeded: 1000"},
+		{"let f = fn() { 1 + f() }; f()", 0, 100, object.CallDepthErrorKind, "maximum call depth exceeded: 100"},
+		{"let f = fn(n) { if (n > 0) { 1 + f(n - 1) } else { 0 } }; f(100)", 0, 100, object.CallDepthErrorKind, "maximum call depth exceeded: 100"},
+		{"let f = fn() { 1 + f() }; f()", 0, DefaultMaxCallDepth, object.CallDepthErrorKind, "maximum call depth exceeded: 10000"},
+		{"try { while (true) {} } catch (e) { 1 }", 1000, 0, object.StepLimitErrorKind, "step limit exceeded: 1000"},
+		{"let f = fn() { 1 + f() }; try { f() } catch (e) { 1 } finally { 2 }", 0, 50, object.CallDepthErrorKind, "maximum call depth exceeded: 50"},
+	}
+
+	for _, tt := range tests {
+		e := New()
+		e.MaxSteps = tt.maxSteps
+		e.MaxCallDepth = tt.maxCallDepth
+
+		evaluated := testEvalContext(e, context.Background(), tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%q: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Kind != tt.kind {
+			t.Errorf("%q: wrong error kind. expected=%q, got=%q", tt.input, tt.kind, errObj.Kind)
+		}
+		if errObj.Message != tt.message {
+			t.Errorf("%q: wrong error message. expected=%q, got=%q", tt.input, tt.message, errObj.Message)
+		}
+	}
+}
+
+func TestExecutionLimitsWithinBudget(t *testing.T) {
+	e := New()
+	e.MaxSteps = 10000
+	e.MaxCallDepth = 20
+
+	input := "let f = fn(n) { if (n > 0) { 1 + f(n - 1) } else { 0 } }; f(19)"
+	testIntegerObject(t, testEvalContext(e, context.Background(), input), 19)
+
+	// 末尾呼び出しは呼び出しの深さを増やさない。
+	input = "let f = fn(n) { if (n > 0) { f(n - 1) } else { 0 } }; f(100)"
+	testIntegerObject(t, testEvalContext(e, context.Background(), input), 0)
+
+	// 評価したノード数は EvalContext の呼び出しごとに数え直す。
+	input = "let i = 0; while (i < 100) { i += 1 }; i"
+	for n := 0; n < 3; n++ {
+		testIntegerObject(t, testEvalContext(e, context.Background(), input), 100)
+	}
+}
+
+func TestMemoryLimit(t *testing.T) {
+	tests := []struct {
+		input    string
+		maxAlloc int
+		expected interface{}
+	}{
+		{`let s = "a"; while (true) { s = s + s }`, 1 << 16, "memory limit exceeded: 65536 bytes"},
+		{"let a = []; while (true) { a = push(a, 1) }", 1 << 16, "memory limit exceeded: 65536 bytes"},
+		{"let h = {}; let i = 0; while (true) { h[i] = i; i += 1 }", 1 << 16, "memory limit exceeded: 65536 bytes"},
+		{"let f = fn(...r) { f(1, 2, 3) }; f()", 1 << 12, "memory limit exceeded: 4096 bytes"},
+		{`let s = "` + strings.Repeat("x", 100) + `"; for (c in s) { c }`, 200, "memory limit exceeded: 200 bytes"},
+		{`try { let s = "a"; while (true) { s = s + s } } catch (e) { 1 }`, 1 << 16, "memory limit exceeded: 65536 bytes"},
+		{"1 << 2147483647", 1 << 20, "memory limit exceeded: 1048576 bytes"},
+		{"let n = 100000000000000000000; while (true) { n = n * n }", 1 << 16, "memory limit exceeded: 65536 bytes"},
+		{"let n = 2; while (true) { n = n * n }", 1 << 16, "memory limit exceeded: 65536 bytes"},
+		{"let n = 1; let i = 0; while (i < 100) { n = n << 1; i += 1 }; n >> 95", 1 << 16, 32},
+		{`let s = "a"; let i = 0; while (i < 10) { s = s + s; i += 1 }; len(s)`, 1 << 16, 1024},
+		// 組み込み関数が引数の値をそのまま返却する場合は加算しない。
+		{"let a = [[1, 2, 3]]; let i = 0; while (i < 1000) { first(a); i += 1 }; i", 1 << 10, 1000},
+		{`let a = ["x"]; let i = 0; while (i < 1000) { last(a); i += 1 }; i`, 1 << 10, 1000},
+	}
+
+	for _, tt := range tests {
+		e := New()
+		e.MaxAlloc = tt.maxAlloc
+
+		evaluated := testEvalContext(e, context.Background(), tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("%q: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Kind != object.MemoryLimitErrorKind {
+				t.Errorf("%q: wrong error kind. got=%q", tt.input, errObj.Kind)
+			}
+			if errObj.Message != expected {
+				t.Errorf("%q: wrong error message. expected=%q, got=%q", tt.input, expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestEvalContextCancellation(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	deadline, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	tests := []struct {
+		ctx     context.Context
+		input   string
+		message string
+	}{
+		{canceled, "1", "execution canceled: context canceled"},
+		{deadline, "while (true) {}", "execution canceled: context deadline exceeded"},
+		{deadline, "try { while (true) {} } catch (e) { 1 }", "execution canceled: context deadline exceeded"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalContext(New(), tt.ctx, tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%q: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Kind != object.CanceledErrorKind {
+			t.Errorf("%q: wrong error kind. got=%q", tt.input, errObj.Kind)
+		}
+		if errObj.Message != tt.message {
+			t.Errorf("%q: wrong error message. expected=%q, got=%q", tt.input, tt.message, errObj.Message)
+		}
+	}
+}
+
+func testEvalContext(e *Evaluator, ctx context.Context, input string) object.Object {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	env := object.NewEnvironment()
+
+	return e.EvalContext(ctx, program, env)
+}
@@ -0,0 +1,107 @@
+package evaluator
+
+import (
+	"io/ioutil"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"path/filepath"
+	"strings"
+)
+
+// moduleExt モジュールのファイルの拡張子。import のパスで拡張子を省略した場合に補う。
+const moduleExt = ".monkey"
+
+// evalImportExpression モジュールを読み込み、export された束縛を保持するモジュールを返却する。
+// モジュールは独立した環境で一度だけ評価され、以降の import では同じモジュールを返却する。
+func (e *Evaluator) evalImportExpression(node *ast.ImportExpression) object.Object {
+	path := resolveModulePath(node.Pos().Filename, node.Path)
+
+	if mod, ok := e.modules[path]; ok {
+		return mod
+	}
+
+	for i, loading := range e.loading {
+		if loading == path {
+			cycle := append(append([]string{}, e.loading[i:]...), path)
+			return newError(object.ImportErrorKind, "import cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	src, err := e.readFile(path)
+	if err != nil {
+		return newError(object.ImportErrorKind, "cannot import %q: %s", node.Path, err)
+	}
+
+	l := lexer.NewWithFilename(path, string(src))
+	p := parser.New(l)
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return newError(object.ImportErrorKind, "cannot import %q: %s", node.Path, p.Errors()[0])
+	}
+
+	env := object.NewEnvironment()
+
+	e.loading = append(e.loading, path)
+	result := e.Eval(program, env)
+	e.loading = e.loading[:len(e.loading)-1]
+
+	if isError(result) {
+		return result
+	}
+
+	mod := &object.Module{Path: path, Exports: map[string]object.Object{}}
+	for _, stmt := range program.Statements {
+		if es, ok := stmt.(*ast.ExportStatement); ok {
+			if val, ok := env.Get(es.Name()); ok {
+				mod.Exports[es.Name()] = val
+			}
+		}
+	}
+
+	if e.modules == nil {
+		e.modules = map[string]*object.Module{}
+	}
+	e.modules[path] = mod
+
+	return mod
+}
+
+// evalModuleIndexExpression モジュールが export した束縛を返却する。
+func evalModuleIndexExpression(module, index object.Object) object.Object {
+	mod := module.(*object.Module)
+	name := index.(*object.String).Value
+
+	if val, ok := mod.Exports[name]; ok {
+		return val
+	}
+
+	return newError(object.NameErrorKind, "module %s has no export: %s", mod.Path, name)
+}
+
+// resolveModulePath import のパスを読み込むファイルのパスへ変換する。
+// 相対パスは import を記述したファイルのディレクトリ(ファイル名がない場合はカレントディレクトリ)を基準とする。
+func resolveModulePath(importer, path string) string {
+	if filepath.Ext(path) == "" {
+		path += moduleExt
+	}
+
+	if !filepath.IsAbs(path) {
+		dir := "."
+		if importer != "" {
+			dir = filepath.Dir(importer)
+		}
+		path = filepath.Join(dir, path)
+	}
+
+	return filepath.Clean(path)
+}
+
+func (e *Evaluator) readFile(filename string) ([]byte, error) {
+	if e.ReadFile != nil {
+		return e.ReadFile(filename)
+	}
+	return ioutil.ReadFile(filename)
+}
@@ -0,0 +1,138 @@
+package evaluator
+
+import (
+	"fmt"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"os"
+	"testing"
+)
+
+// testFiles モジュールのテストで使用するファイルの内容
+var testFiles = map[string]string{
+	"lib/math.monkey": `
+export fn add(x, y) { x + y }
+export let pi = 3;
+let secret = 42;
+export fn area(r) { pi * r * r }
+`,
+	"lib/counter.monkey": `
+let n = 0;
+n += 1;
+export let loads = n;
+`,
+	"lib/uses_math.monkey": `
+let m = import "math";
+export fn double(x) { m["add"](x, x) }
+`,
+	"cycle/a.monkey":   `let b = import "b"; export let x = 1;`,
+	"cycle/b.monkey":   `let a = import "a"; export let y = 2;`,
+	"broken.monkey":    `let x = ;`,
+	"failing.monkey":   "export let x = 1;\nlet y = x + true;",
+	"counter_a.monkey": `let c = import "lib/counter"; export let loads = c["loads"];`,
+}
+
+func testEvalModule(input string) object.Object {
+	l := lexer.NewWithFilename("main.monkey", input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	e := New()
+	e.ReadFile = func(filename string) ([]byte, error) {
+		src, ok := testFiles[filename]
+		if !ok {
+			return nil, fmt.Errorf("open %s: %w", filename, os.ErrNotExist)
+		}
+		return []byte(src), nil
+	}
+
+	return e.Eval(program, object.NewEnvironment())
+}
+
+func TestImportExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let m = import "lib/math"; m["add"](1, 2);`, 3},
+		{`let m = import "lib/math.monkey"; m["pi"];`, 3},
+		{`let m = import "./lib/math"; m["area"](2);`, 12},
+		{`let u = import "lib/uses_math"; u["double"](21);`, 42},
+		{`let a = import "lib/counter"; let b = import "lib/counter"; a == b;`, true},
+		{`let a = import "counter_a"; let b = import "lib/counter"; a["loads"] + b["loads"];`, 2},
+		{`let m = import "lib/math"; try { m["secret"] } catch (e) { e["kind"] }`, "NameError"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalModule(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok {
+				t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if str.Value != expected {
+				t.Errorf("String has wrong value. got=%q, want=%q", str.Value, expected)
+			}
+		}
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedKind    string
+		expectedInspect string
+	}{
+		{
+			`import "missing"`,
+			object.ImportErrorKind,
+			`ERROR: main.monkey:1:1: cannot import "missing": open missing.monkey: file does not exist`,
+		},
+		{
+			`import "cycle/a"`,
+			object.ImportErrorKind,
+			"ERROR: cycle/b.monkey:1:9: import cycle: cycle/a.monkey -> cycle/b.monkey -> cycle/a.monkey",
+		},
+		{
+			`import "broken"`,
+			object.ImportErrorKind,
+			`ERROR: main.monkey:1:1: cannot import "broken": broken.monkey:1:9: no prefix parse function for ; found`,
+		},
+		{
+			`import "failing"`,
+			object.TypeErrorKind,
+			"ERROR: failing.monkey:2:11: type mismatch: INTEGER + BOOLEAN",
+		},
+		{
+			`let m = import "lib/math"; m["nothing"]`,
+			object.NameErrorKind,
+			"ERROR: main.monkey:1:29: module lib/math.monkey has no export: nothing",
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalModule(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Kind != tt.expectedKind {
+			t.Errorf("wrong error kind. expected=%q, got=%q", tt.expectedKind, errObj.Kind)
+		}
+
+		if errObj.Inspect() != tt.expectedInspect {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expectedInspect, errObj.Inspect())
+		}
+	}
+}
@@ -0,0 +1,494 @@
+package interpreter
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"monkey/object"
+	"reflect"
+	"sort"
+)
+
+var (
+	objectType = reflect.TypeOf((*object.Object)(nil)).Elem()
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+	bigIntType = reflect.TypeOf((*big.Int)(nil))
+)
+
+// ToObject Goの値をオブジェクトへ変換する。
+// nil は null、真偽値・整数・浮動小数点数・文字列はそれぞれ対応するオブジェクト(int64 に収まらない整数は BIGINT)、
+// スライスと配列は配列、マップと構造体はハッシュ、関数は組み込み関数となり、ポインタは指す先の値を変換する。
+// 構造体は公開されたフィールドを、フィールド名(`monkey:"name"` タグで変更でき、"-" の場合は除外する)をキーとして格納する。
+// object.Object はそのまま返却する。自身を含む循環した値は変換できずエラーを返却する。
+func ToObject(v interface{}) (object.Object, error) {
+	return toObject(reflect.ValueOf(v), map[visit]bool{})
+}
+
+// visit 変換中のポインタ、スライス、マップを識別する値
+type visit struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+// toObject rv をオブジェクトへ変換する。visiting は変換中の値の集合で、
+// 変換中の値に再び到達した場合は循環した値としてエラーを返却する。
+func toObject(rv reflect.Value, visiting map[visit]bool) (object.Object, error) {
+	if !rv.IsValid() {
+		return object.NullValue, nil
+	}
+
+	if rv.Type().Implements(objectType) {
+		if rv.Kind() == reflect.Interface || rv.Kind() == reflect.Ptr {
+			if rv.IsNil() {
+				return object.NullValue, nil
+			}
+		}
+		return rv.Interface().(object.Object), nil
+	}
+
+	if rv.Type() == bigIntType {
+		if rv.IsNil() {
+			return object.NullValue, nil
+		}
+		return object.NewBigInt(new(big.Int).Set(rv.Interface().(*big.Int))), nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map:
+		if rv.IsNil() {
+			break
+		}
+
+		v := visit{ptr: rv.Pointer(), typ: rv.Type()}
+		if rv.Kind() == reflect.Slice {
+			v.len = rv.Len()
+		}
+		if visiting[v] {
+			return nil, fmt.Errorf("cannot convert cyclic value of type %s to object", rv.Type())
+		}
+		visiting[v] = true
+		defer delete(visiting, v)
+	}
+
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return object.NullValue, nil
+		}
+		return toObject(rv.Elem(), visiting)
+
+	case reflect.Bool:
+		return object.NewBoolean(rv.Bool()), nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: rv.Int()}, nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := rv.Uint()
+		if u > math.MaxInt64 {
+			return &object.BigInt{Value: new(big.Int).SetUint64(u)}, nil
+		}
+		return &object.Integer{Value: int64(u)}, nil
+
+	case reflect.Float32, reflect.Float64:
+		return &object.Float{Value: rv.Float()}, nil
+
+	case reflect.String:
+		return &object.String{Value: rv.String()}, nil
+
+	case reflect.Slice, reflect.Array:
+		elements := make([]object.Object, rv.Len())
+		for i := range elements {
+			elem, err := toObject(rv.Index(i), visiting)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = elem
+		}
+		return &object.Array{Elements: elements}, nil
+
+	case reflect.Map:
+		hash := object.NewHash(rv.Len())
+		for _, k := range sortedMapKeys(rv) {
+			if err := setHashPair(hash, k, rv.MapIndex(k), visiting); err != nil {
+				return nil, err
+			}
+		}
+		return hash, nil
+
+	case reflect.Struct:
+		hash := object.NewHash(rv.NumField())
+		for _, f := range structFields(rv.Type()) {
+			if err := setHashPair(hash, reflect.ValueOf(f.key), rv.Field(f.index), visiting); err != nil {
+				return nil, err
+			}
+		}
+		return hash, nil
+
+	case reflect.Func:
+		if rv.IsNil() {
+			return object.NullValue, nil
+		}
+		return wrapFunction("function", rv)
+
+	default:
+		return nil, fmt.Errorf("cannot convert %s to object", rv.Type())
+	}
+}
+
+func setHashPair(hash *object.Hash, k, v reflect.Value, visiting map[visit]bool) error {
+	key, err := toObject(k, visiting)
+	if err != nil {
+		return err
+	}
+
+	hashable, ok := object.AsHashable(key)
+	if !ok {
+		return fmt.Errorf("unusable as hash key: %s", key.Type())
+	}
+
+	value, err := toObject(v, visiting)
+	if err != nil {
+		return err
+	}
+
+	hash.Set(hashable, value)
+	return nil
+}
+
+// sortedMapKeys マップのキーを返却する。ハッシュの順序を一定にするため、文字列と数値のキーは昇順に並べる。
+func sortedMapKeys(rv reflect.Value) []reflect.Value {
+	keys := rv.MapKeys()
+
+	var less func(a, b reflect.Value) bool
+	switch rv.Type().Key().Kind() {
+	case reflect.String:
+		less = func(a, b reflect.Value) bool { return a.String() < b.String() }
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		less = func(a, b reflect.Value) bool { return a.Int() < b.Int() }
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		less = func(a, b reflect.Value) bool { return a.Uint() < b.Uint() }
+	case reflect.Float32, reflect.Float64:
+		less = func(a, b reflect.Value) bool { return a.Float() < b.Float() }
+	default:
+		return keys
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return less(keys[i], keys[j]) })
+	return keys
+}
+
+// structField ハッシュへ変換する構造体のフィールド
+type structField struct {
+	key   string // ハッシュのキー
+	index int    // フィールドの添字
+}
+
+// structFields 構造体のうちハッシュへ変換するフィールドを返却する。
+func structFields(t reflect.Type) []structField {
+	fields := []structField{}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		key := f.Name
+		if tag, ok := f.Tag.Lookup("monkey"); ok {
+			if tag == "-" {
+				continue
+			}
+			if tag != "" {
+				key = tag
+			}
+		}
+
+		fields = append(fields, structField{key: key, index: i})
+	}
+
+	return fields
+}
+
+// FromObject オブジェクトをGoの値へ変換する。
+// INTEGER は int64、BIGINT は *big.Int、FLOAT は float64、STRING は string、BOOLEAN は bool、null は nil、
+// 配列は []interface{}、ハッシュはキーが全て文字列の場合は map[string]interface{}、それ以外の場合は map[interface{}]interface{} となる。
+// map[interface{}]interface{} では、BIGINT と配列のキーは文字列表現に変換する。
+// 関数などのGoの値に対応しないオブジェクトはそのまま返却する。
+func FromObject(obj object.Object) interface{} {
+	switch obj := obj.(type) {
+	case nil, *object.Null:
+		return nil
+	case *object.Integer:
+		return obj.Value
+	case *object.BigInt:
+		return new(big.Int).Set(obj.Value)
+	case *object.Float:
+		return obj.Value
+	case *object.String:
+		return obj.Value
+	case *object.Boolean:
+		return obj.Value
+	case *object.Array:
+		values := make([]interface{}, len(obj.Elements))
+		for i, elem := range obj.Elements {
+			values[i] = FromObject(elem)
+		}
+		return values
+	case *object.Hash:
+		if stringKeys(obj) {
+			values := make(map[string]interface{}, obj.Len())
+			for _, pair := range obj.Pairs() {
+				values[pair.Key.(*object.String).Value] = FromObject(pair.Value)
+			}
+			return values
+		}
+
+		values := make(map[interface{}]interface{}, obj.Len())
+		for _, pair := range obj.Pairs() {
+			var key interface{}
+			switch k := pair.Key.(type) {
+			case *object.BigInt, *object.Array:
+				// *big.Int と []interface{} はマップのキーとして比較できないため、文字列表現をキーとする。
+				key = k.Inspect()
+			default:
+				key = FromObject(k)
+			}
+			values[key] = FromObject(pair.Value)
+		}
+		return values
+	default:
+		return obj
+	}
+}
+
+func stringKeys(hash *object.Hash) bool {
+	for _, pair := range hash.Pairs() {
+		if pair.Key.Type() != object.StringObj {
+			return false
+		}
+	}
+	return true
+}
+
+// Decode オブジェクトを out が指すGoの値へ変換して格納する。out は nil でないポインタでなければならない。
+// 数値は格納先の型に収まる場合のみ変換し、INTEGER は浮動小数点数にも変換できる。
+// 構造体へはハッシュからフィールド名(ToObject と同じ規則)をキーとして値を格納し、キーが存在しないフィールドは変更しない。
+// interface{} へは FromObject の結果を格納する。
+func Decode(obj object.Object, out interface{}) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("cannot decode into %T", out)
+	}
+	return decode(obj, rv.Elem())
+}
+
+func decode(obj object.Object, rv reflect.Value) error {
+	if rv.Type() == objectType {
+		rv.Set(reflect.ValueOf(obj))
+		return nil
+	}
+
+	if rv.Type() == bigIntType {
+		if n := object.ToBigInt(obj); n != nil {
+			rv.Set(reflect.ValueOf(n))
+			return nil
+		}
+	}
+
+	switch rv.Kind() {
+	case reflect.Interface:
+		if rv.NumMethod() == 0 {
+			if v := FromObject(obj); v != nil {
+				rv.Set(reflect.ValueOf(v))
+			} else {
+				rv.Set(reflect.Zero(rv.Type()))
+			}
+			return nil
+		}
+		if reflect.TypeOf(obj).Implements(rv.Type()) {
+			rv.Set(reflect.ValueOf(obj))
+			return nil
+		}
+
+	case reflect.Ptr:
+		if obj.Type() == object.NullObj {
+			rv.Set(reflect.Zero(rv.Type()))
+			return nil
+		}
+		elem := reflect.New(rv.Type().Elem())
+		if err := decode(obj, elem.Elem()); err != nil {
+			return err
+		}
+		rv.Set(elem)
+		return nil
+
+	case reflect.Bool:
+		if b, ok := obj.(*object.Boolean); ok {
+			rv.SetBool(b.Value)
+			return nil
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i, ok := obj.(*object.Integer); ok {
+			if rv.OverflowInt(i.Value) {
+				return fmt.Errorf("%d overflows %s", i.Value, rv.Type())
+			}
+			rv.SetInt(i.Value)
+			return nil
+		}
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if n := object.ToBigInt(obj); n != nil {
+			if n.Sign() < 0 || !n.IsUint64() || rv.OverflowUint(n.Uint64()) {
+				return fmt.Errorf("%s overflows %s", n, rv.Type())
+			}
+			rv.SetUint(n.Uint64())
+			return nil
+		}
+
+	case reflect.Float32, reflect.Float64:
+		switch n := obj.(type) {
+		case *object.Float:
+			rv.SetFloat(n.Value)
+			return nil
+		case *object.Integer:
+			rv.SetFloat(float64(n.Value))
+			return nil
+		}
+
+	case reflect.String:
+		if s, ok := obj.(*object.String); ok {
+			rv.SetString(s.Value)
+			return nil
+		}
+
+	case reflect.Slice:
+		if obj.Type() == object.NullObj {
+			rv.Set(reflect.Zero(rv.Type()))
+			return nil
+		}
+		if arr, ok := obj.(*object.Array); ok {
+			s := reflect.MakeSlice(rv.Type(), len(arr.Elements), len(arr.Elements))
+			for i, elem := range arr.Elements {
+				if err := decode(elem, s.Index(i)); err != nil {
+					return err
+				}
+			}
+			rv.Set(s)
+			return nil
+		}
+
+	case reflect.Array:
+		if arr, ok := obj.(*object.Array); ok {
+			if len(arr.Elements) != rv.Len() {
+				return fmt.Errorf("cannot convert ARRAY of length %d to %s", len(arr.Elements), rv.Type())
+			}
+			for i, elem := range arr.Elements {
+				if err := decode(elem, rv.Index(i)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+	case reflect.Map:
+		if hash, ok := obj.(*object.Hash); ok {
+			m := reflect.MakeMapWithSize(rv.Type(), hash.Len())
+			for _, pair := range hash.Pairs() {
+				k := reflect.New(rv.Type().Key()).Elem()
+				if err := decode(pair.Key, k); err != nil {
+					return err
+				}
+				v := reflect.New(rv.Type().Elem()).Elem()
+				if err := decode(pair.Value, v); err != nil {
+					return err
+				}
+				m.SetMapIndex(k, v)
+			}
+			rv.Set(m)
+			return nil
+		}
+
+	case reflect.Struct:
+		if hash, ok := obj.(*object.Hash); ok {
+			for _, f := range structFields(rv.Type()) {
+				key := &object.String{Value: f.key}
+				pair, ok := hash.Get(key)
+				if !ok {
+					continue
+				}
+				if err := decode(pair.Value, rv.Field(f.index)); err != nil {
+					return fmt.Errorf("field %s: %s", f.key, err)
+				}
+			}
+			return nil
+		}
+	}
+
+	return fmt.Errorf("cannot convert %s to %s", obj.Type(), rv.Type())
+}
+
+// wrapFunction Goの関数を、引数と戻り値を自動的に変換する組み込み関数へ変換する。
+func wrapFunction(name string, fn reflect.Value) (*object.Builtin, error) {
+	t := fn.Type()
+
+	switch {
+	case t.NumOut() > 2:
+		return nil, fmt.Errorf("function %s returns too many values: %d", name, t.NumOut())
+	case t.NumOut() == 2 && t.Out(1) != errorType:
+		return nil, fmt.Errorf("second result of function %s must be error, got %s", name, t.Out(1))
+	}
+
+	numIn := t.NumIn()
+
+	return &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		if (t.IsVariadic() && len(args) < numIn-1) || (!t.IsVariadic() && len(args) != numIn) {
+			want := fmt.Sprint(numIn)
+			if t.IsVariadic() {
+				want = fmt.Sprintf("at least %d", numIn-1)
+			}
+			return object.NewError(object.ArgumentErrorKind, "wrong number of arguments: want=%s, got=%d", want, len(args))
+		}
+
+		in := make([]reflect.Value, len(args))
+		for i, arg := range args {
+			pt := t.In(numIn - 1)
+			if !t.IsVariadic() || i < numIn-1 {
+				pt = t.In(i)
+			} else {
+				pt = pt.Elem()
+			}
+
+			v := reflect.New(pt).Elem()
+			if err := decode(arg, v); err != nil {
+				return object.NewError(object.TypeErrorKind, "argument %d to `%s`: %s", i+1, name, err)
+			}
+			in[i] = v
+		}
+
+		out := fn.Call(in)
+
+		if len(out) > 0 && t.Out(len(out)-1) == errorType {
+			if err := out[len(out)-1]; !err.IsNil() {
+				// 同じエラーが繰り返し返却された場合に位置や呼び出し履歴が混ざらないよう、複製して返却する。
+				if errObj, ok := err.Interface().(*object.Error); ok {
+					return &object.Error{Kind: errObj.Kind, Message: errObj.Message, Value: errObj.Value}
+				}
+				return object.NewError(object.ErrorKind, "%s", err.Interface().(error).Error())
+			}
+			out = out[:len(out)-1]
+		}
+
+		if len(out) == 0 {
+			return nil
+		}
+
+		result, err := toObject(out[0], map[visit]bool{})
+		if err != nil {
+			return object.NewError(object.TypeErrorKind, "result of `%s`: %s", name, err)
+		}
+		return result
+	}}, nil
+}
@@ -0,0 +1,216 @@
+package interpreter
+
+import (
+	"math"
+	"math/big"
+	"monkey/object"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestToObject(t *testing.T) {
+	type inner struct {
+		Tags []string `monkey:"tags"`
+	}
+	type config struct {
+		Name    string `monkey:"name"`
+		Port    uint16
+		Debug   bool
+		Ratio   float64
+		Inner   *inner
+		Skipped int `monkey:"-"`
+		private int
+	}
+
+	tests := []struct {
+		input    interface{}
+		expected string
+	}{
+		{nil, "null"},
+		{true, "true"},
+		{42, "42"},
+		{int8(-3), "-3"},
+		{uint64(math.MaxUint64), "18446744073709551615"},
+		{1.5, "1.5"},
+		{"hello", "hello"},
+		{[]int{1, 2, 3}, "[1, 2, 3]"},
+		{[2]string{"a", "b"}, "[a, b]"},
+		{[]interface{}{1, "x", nil}, "[1, x, null]"},
+		{map[string]int{"a": 1}, "{a: 1}"},
+		{map[string]int{"c": 3, "a": 1, "b": 2}, "{a: 1, b: 2, c: 3}"},
+		{map[int]bool{3: true, -1: false, 2: true}, "{-1: false, 2: true, 3: true}"},
+		{map[float64]int{2.5: 1, 0.5: 2}, "{0.5: 2, 2.5: 1}"},
+		{map[[2]int]string{{1, 2}: "a"}, "{[1, 2]: a}"},
+		{big.NewInt(7), "7"},
+		{(*inner)(nil), "null"},
+		{&object.Integer{Value: 9}, "9"},
+		{inner{Tags: []string{"x"}}, "{tags: [x]}"},
+	}
+
+	for _, tt := range tests {
+		obj, err := ToObject(tt.input)
+		if err != nil {
+			t.Errorf("ToObject(%#v): unexpected error: %s", tt.input, err)
+			continue
+		}
+		if obj.Inspect() != tt.expected {
+			t.Errorf("ToObject(%#v): expected=%q, got=%q", tt.input, tt.expected, obj.Inspect())
+		}
+	}
+
+	obj, err := ToObject(config{Name: "svc", Port: 8080, Debug: true, Ratio: 0.5, Inner: &inner{}, Skipped: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	got := FromObject(obj)
+	expected := map[string]interface{}{
+		"name":  "svc",
+		"Port":  int64(8080),
+		"Debug": true,
+		"Ratio": 0.5,
+		"Inner": map[string]interface{}{"tags": []interface{}{}},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong struct conversion. expected=%#v, got=%#v", expected, got)
+	}
+
+	if _, err := ToObject(map[interface{}]int{nil: 1}); err == nil {
+		t.Errorf("expected error for unhashable key")
+	}
+	if _, err := ToObject(make(chan int)); err == nil {
+		t.Errorf("expected error for channel")
+	}
+
+	if obj, _ := ToObject(true); obj != object.True {
+		t.Errorf("true is not object.True")
+	}
+	if obj, _ := ToObject(nil); obj != object.NullValue {
+		t.Errorf("nil is not object.NullValue")
+	}
+}
+
+func TestToObjectCycles(t *testing.T) {
+	type node struct {
+		Next *node
+	}
+
+	n := &node{}
+	n.Next = n
+
+	m := map[string]interface{}{}
+	m["self"] = m
+
+	sl := []interface{}{nil}
+	sl[0] = sl
+
+	for _, v := range []interface{}{n, m, sl} {
+		if _, err := ToObject(v); err == nil || !strings.Contains(err.Error(), "cyclic value") {
+			t.Errorf("expected cyclic value error for %T. got=%v", v, err)
+		}
+	}
+
+	// 同じ値を複数回参照しているだけの場合は変換できる。
+	shared := &node{}
+	obj, err := ToObject([]*node{shared, shared})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if obj.Inspect() != "[{Next: null}, {Next: null}]" {
+		t.Errorf("wrong result. got=%s", obj.Inspect())
+	}
+}
+
+func TestFromObject(t *testing.T) {
+	hash, _ := ToObject(map[int]string{1: "a"})
+	arrayKeyHash, _ := ToObject(map[[2]interface{}]float64{{1, "x"}: 2.5})
+
+	tests := []struct {
+		input    object.Object
+		expected interface{}
+	}{
+		{object.NullValue, nil},
+		{&object.Integer{Value: 5}, int64(5)},
+		{&object.BigInt{Value: new(big.Int).Lsh(big.NewInt(1), 70)}, new(big.Int).Lsh(big.NewInt(1), 70)},
+		{&object.Float{Value: 2.5}, 2.5},
+		{&object.String{Value: "s"}, "s"},
+		{object.False, false},
+		{&object.Array{Elements: []object.Object{&object.Integer{Value: 1}, object.NullValue}}, []interface{}{int64(1), nil}},
+		{hash, map[interface{}]interface{}{int64(1): "a"}},
+		{arrayKeyHash, map[interface{}]interface{}{"[1, x]": 2.5}},
+	}
+
+	for _, tt := range tests {
+		got := FromObject(tt.input)
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("FromObject(%s): expected=%#v, got=%#v", tt.input.Inspect(), tt.expected, got)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	type rule struct {
+		Name    string   `monkey:"name"`
+		Limit   int      `monkey:"limit"`
+		Weight  float64  `monkey:"weight"`
+		Enabled *bool    `monkey:"enabled"`
+		Tags    []string `monkey:"tags"`
+		Extra   interface{}
+		Default string
+	}
+
+	in := New()
+	obj, err := in.Eval(`{"name": "r1", "limit": 10, "weight": 2, "enabled": true, "tags": ["a", "b"], "Extra": [1]}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	r := rule{Default: "kept"}
+	if err := Decode(obj, &r); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	enabled := true
+	expected := rule{
+		Name:    "r1",
+		Limit:   10,
+		Weight:  2,
+		Enabled: &enabled,
+		Tags:    []string{"a", "b"},
+		Extra:   []interface{}{int64(1)},
+		Default: "kept",
+	}
+	if !reflect.DeepEqual(r, expected) {
+		t.Errorf("wrong decode. expected=%+v, got=%+v", expected, r)
+	}
+
+	errorTests := []struct {
+		input    object.Object
+		out      interface{}
+		expected string
+	}{
+		{&object.Integer{Value: 300}, new(int8), "300 overflows int8"},
+		{&object.Integer{Value: -1}, new(uint), "-1 overflows uint"},
+		{&object.String{Value: "x"}, new(bool), "cannot convert STRING to bool"},
+		{&object.Array{Elements: []object.Object{}}, new([1]int), "cannot convert ARRAY of length 0 to [1]int"},
+		{&object.Integer{Value: 1}, 0, "cannot decode into int"},
+	}
+
+	for _, tt := range errorTests {
+		err := Decode(tt.input, tt.out)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("Decode(%s): wrong error. expected=%q, got=%v", tt.input.Inspect(), tt.expected, err)
+		}
+	}
+
+	var m map[string]int
+	hash, _ := ToObject(map[string]int{"a": 1, "b": 2})
+	if err := Decode(hash, &m); err != nil || !reflect.DeepEqual(m, map[string]int{"a": 1, "b": 2}) {
+		t.Errorf("wrong map decode. got=%v (%v)", m, err)
+	}
+
+	var n *big.Int
+	if err := Decode(&object.Integer{Value: 3}, &n); err != nil || n.Int64() != 3 {
+		t.Errorf("wrong big.Int decode. got=%v (%v)", n, err)
+	}
+}
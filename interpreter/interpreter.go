@@ -0,0 +1,155 @@
+package interpreter
+
+import (
+	"context"
+	"errors"
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"reflect"
+	"strings"
+)
+
+// Interpreter Goのプログラムに組み込んで使用するインタプリタ。
+// グローバル変数と登録した組み込み関数は、同じインタプリタで実行する全てのプログラムで共有される。
+type Interpreter struct {
+	evaluator *evaluator.Evaluator
+	env       *object.Environment
+}
+
+// New インタプリタを生成する。
+func New() *Interpreter {
+	return &Interpreter{
+		evaluator: evaluator.New(),
+		env:       object.NewEnvironment(),
+	}
+}
+
+// Evaluator プログラムの実行に使用する評価器を返却する。評価時の設定の変更に使用する。
+func (in *Interpreter) Evaluator() *evaluator.Evaluator { return in.evaluator }
+
+// Program 構文解析済みのプログラム。同じプログラムを繰り返し実行できる。
+type Program struct {
+	program *ast.Program
+}
+
+// String プログラムの文字列表現を返却する。
+func (p *Program) String() string { return p.program.String() }
+
+// Compile ソースコードを構文解析し、プログラムを返却する。
+// filename はエラーの位置と import の基準ディレクトリに使用する(空文字の場合はカレントディレクトリを基準とする)。
+// 構文エラーがある場合は、全てのエラーを1行ずつ含むエラーを返却する。
+func (in *Interpreter) Compile(filename, src string) (*Program, error) {
+	l := lexer.NewWithFilename(filename, src)
+	p := parser.New(l)
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, errors.New(strings.Join(p.Errors(), "\n"))
+	}
+
+	return &Program{program: program}, nil
+}
+
+// Run プログラムを実行し、最後に評価した値を返却する。
+// 実行時エラーは *object.Error として返却する。
+func (in *Interpreter) Run(p *Program) (object.Object, error) {
+	return in.RunContext(context.Background(), p)
+}
+
+// RunContext コンテキストが取り消されるか期限を過ぎた場合に実行を中断するようにして、プログラムを実行する。
+// 評価するノード数、関数呼び出しの深さ、割り当て量の上限は Evaluator で設定する。
+func (in *Interpreter) RunContext(ctx context.Context, p *Program) (object.Object, error) {
+	return result(in.evaluator.EvalContext(ctx, p.program, in.env))
+}
+
+// Eval ソースコードを構文解析して実行する。
+func (in *Interpreter) Eval(src string) (object.Object, error) {
+	p, err := in.Compile("", src)
+	if err != nil {
+		return nil, err
+	}
+	return in.Run(p)
+}
+
+// Call グローバル変数に束縛された関数を、Goの値から変換した引数で呼び出す。
+func (in *Interpreter) Call(name string, args ...interface{}) (object.Object, error) {
+	return in.CallContext(context.Background(), name, args...)
+}
+
+// CallContext コンテキストが取り消されるか期限を過ぎた場合に実行を中断するようにして、関数を呼び出す。
+// 評価するノード数と割り当て量の上限は、RunContext と同様に呼び出しごとに適用する。
+// 登録した Go の関数の中から呼び出した場合は、外側の実行の一部として数える。
+func (in *Interpreter) CallContext(ctx context.Context, name string, args ...interface{}) (object.Object, error) {
+	fn, ok := in.Get(name)
+	if !ok {
+		return nil, object.NewError(object.NameErrorKind, "identifier not found: %s", name)
+	}
+
+	objs := make([]object.Object, len(args))
+	for i, arg := range args {
+		obj, err := ToObject(arg)
+		if err != nil {
+			return nil, err
+		}
+		objs[i] = obj
+	}
+
+	return result(in.evaluator.ApplyContext(ctx, fn, objs))
+}
+
+// RegisterBuiltin 組み込み関数を登録する。同じ名前の組み込み関数が存在する場合は置き換える。
+func (in *Interpreter) RegisterBuiltin(name string, fn object.BuiltinFunction) {
+	if in.evaluator.Builtins == nil {
+		in.evaluator.Builtins = map[string]*object.Builtin{}
+	}
+	in.evaluator.Builtins[name] = &object.Builtin{Fn: fn}
+}
+
+// RegisterFunction Goの関数を組み込み関数として登録する。
+// 引数は Decode と同じ規則で関数の仮引数の型へ変換し、戻り値は ToObject で変換する。
+// 関数の戻り値は、なし、値、error、または値と error のいずれかでなければならない。
+// 返却された error は Monkey のエラーとなる(*object.Error の場合は種別を保持する)。
+func (in *Interpreter) RegisterFunction(name string, fn interface{}) error {
+	rv := reflect.ValueOf(fn)
+	if rv.Kind() != reflect.Func {
+		return errors.New("not a function: " + rv.Kind().String())
+	}
+
+	builtin, err := wrapFunction(name, rv)
+	if err != nil {
+		return err
+	}
+
+	in.RegisterBuiltin(name, builtin.Fn)
+	return nil
+}
+
+// Set Goの値をオブジェクトへ変換し、グローバル変数に束縛する。
+func (in *Interpreter) Set(name string, v interface{}) error {
+	obj, err := ToObject(v)
+	if err != nil {
+		return err
+	}
+
+	in.env.Set(name, obj)
+	return nil
+}
+
+// Get グローバル変数に束縛された値を返却する。束縛されていない場合は false を返却する。
+func (in *Interpreter) Get(name string) (object.Object, bool) {
+	return in.env.Get(name)
+}
+
+// result 評価結果を Go の戻り値の形式へ変換する。
+func result(obj object.Object) (object.Object, error) {
+	if err, ok := obj.(*object.Error); ok {
+		return nil, err
+	}
+	if obj == nil {
+		return object.NullValue, nil
+	}
+	return obj, nil
+}
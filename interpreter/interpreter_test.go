@@ -0,0 +1,308 @@
+package interpreter
+
+import (
+	"context"
+	"errors"
+	"monkey/object"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEval(t *testing.T) {
+	in := New()
+
+	obj, err := in.Eval("let x = 2; x * 21")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if FromObject(obj) != int64(42) {
+		t.Errorf("wrong result. got=%s", obj.Inspect())
+	}
+
+	// グローバル変数は以降の実行でも参照できる。
+	obj, err = in.Eval("x + 1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if FromObject(obj) != int64(3) {
+		t.Errorf("wrong result. got=%s", obj.Inspect())
+	}
+
+	obj, err = in.Eval("let y = 1;")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if obj != object.NullValue {
+		t.Errorf("result is not null. got=%s", obj.Inspect())
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	in := New()
+
+	_, err := in.Eval("let x 5;\nlet = 1;")
+	if err == nil {
+		t.Fatal("expected syntax error")
+	}
+	lines := strings.Split(err.Error(), "\n")
+	if len(lines) < 2 || lines[0] != "1:7: expected next token to be =, got INT instead" {
+		t.Errorf("expected all syntax errors. got=%q", err.Error())
+	}
+
+	_, err = in.Eval("1 / 0")
+	var errObj *object.Error
+	if !errors.As(err, &errObj) {
+		t.Fatalf("error is not *object.Error. got=%T (%v)", err, err)
+	}
+	if errObj.Kind != object.ZeroDivisionErrorKind {
+		t.Errorf("wrong error kind. got=%q", errObj.Kind)
+	}
+	if err.Error() != "ERROR: 1:3: division by zero: 1 / 0" {
+		t.Errorf("wrong error message. got=%q", err.Error())
+	}
+}
+
+func TestCompileAndRun(t *testing.T) {
+	in := New()
+
+	p, err := in.Compile("rules.monkey", "counter += 1; counter")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := in.Set("counter", 0); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for i := 1; i <= 3; i++ {
+		obj, err := in.Run(p)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if FromObject(obj) != int64(i) {
+			t.Errorf("wrong result. expected=%d, got=%s", i, obj.Inspect())
+		}
+	}
+
+	counter, ok := in.Get("counter")
+	if !ok || FromObject(counter) != int64(3) {
+		t.Errorf("wrong counter. got=%v", counter)
+	}
+
+	if _, ok := in.Get("missing"); ok {
+		t.Errorf("missing global should not be found")
+	}
+}
+
+func TestRegisterFunction(t *testing.T) {
+	type point struct {
+		X, Y int
+	}
+
+	in := New()
+
+	funcs := map[string]interface{}{
+		"add":   func(a, b int) int { return a + b },
+		"join":  func(sep string, parts ...string) string { return strings.Join(parts, sep) },
+		"norm1": func(p point) int { return p.X + p.Y },
+		"mkpt":  func(x, y int) point { return point{X: x, Y: y} },
+		"check": func(n int) (int, error) {
+			if n < 0 {
+				return 0, errors.New("negative")
+			}
+			return n, nil
+		},
+		"typed": func() error { return object.NewError(object.ValueErrorKind, "bad value") },
+		"noop":  func() {},
+	}
+	for name, fn := range funcs {
+		if err := in.RegisterFunction(name, fn); err != nil {
+			t.Fatalf("RegisterFunction(%s): %s", name, err)
+		}
+	}
+
+	in.RegisterBuiltin("raw", func(args ...object.Object) object.Object {
+		return &object.Integer{Value: int64(len(args))}
+	})
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"add(1, 2)", int64(3)},
+		{`join("-", "a", "b", "c")`, "a-b-c"},
+		{`join(",")`, ""},
+		{`norm1({"X": 3, "Y": 4})`, int64(7)},
+		{`mkpt(1, 2)["Y"]`, int64(2)},
+		{"check(5)", int64(5)},
+		{"try { check(-1) } catch (e) { e[\"message\"] }", "negative"},
+		{"try { typed() } catch (e) { e[\"kind\"] }", object.ValueErrorKind},
+		{"noop()", nil},
+		{"raw(1, 2, 3)", int64(3)},
+		{"let f = fn(g) { g(2, 3) }; f(add)", int64(5)},
+	}
+
+	for _, tt := range tests {
+		obj, err := in.Eval(tt.input)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", tt.input, err)
+			continue
+		}
+		if got := FromObject(obj); got != tt.expected {
+			t.Errorf("%q: wrong result. expected=%v, got=%v", tt.input, tt.expected, got)
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"add(1)", "ERROR: 1:4: wrong number of arguments: want=2, got=1"},
+		{"join()", "ERROR: 1:5: wrong number of arguments: want=at least 1, got=0"},
+		{`add(1, "2")`, "ERROR: 1:4: argument 2 to `add`: cannot convert STRING to int"},
+		{`norm1({"X": "a"})`, "ERROR: 1:6: argument 1 to `norm1`: field X: cannot convert STRING to int"},
+	}
+
+	for _, tt := range errorTests {
+		_, err := in.Eval(tt.input)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("%q: wrong error. expected=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+
+	if err := in.RegisterFunction("bad", 1); err == nil {
+		t.Errorf("expected error for non-function")
+	}
+	if err := in.RegisterFunction("bad", func() (int, int) { return 0, 0 }); err == nil {
+		t.Errorf("expected error for invalid results")
+	}
+}
+
+func TestCall(t *testing.T) {
+	in := New()
+
+	if _, err := in.Eval("let greet = fn(name, n = 1) { name + \"!\" }"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	obj, err := in.Call("greet", "hi")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if FromObject(obj) != "hi!" {
+		t.Errorf("wrong result. got=%s", obj.Inspect())
+	}
+
+	if _, err := in.Call("greet"); err == nil {
+		t.Errorf("expected arity error")
+	}
+
+	if _, err := in.Call("missing"); err == nil || err.Error() != "ERROR: identifier not found: missing" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+}
+
+func TestRunContext(t *testing.T) {
+	in := New()
+	in.Evaluator().MaxSteps = 1000
+
+	p, err := in.Compile("", "while (true) {}")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var errObj *object.Error
+	if _, err := in.Run(p); !errors.As(err, &errObj) || errObj.Kind != object.StepLimitErrorKind {
+		t.Errorf("expected step limit error. got=%v", err)
+	}
+
+	in.Evaluator().MaxSteps = 0
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := in.RunContext(ctx, p); !errors.As(err, &errObj) || errObj.Kind != object.CanceledErrorKind {
+		t.Errorf("expected canceled error. got=%v", err)
+	}
+}
+
+func TestCallContext(t *testing.T) {
+	in := New()
+	in.Evaluator().MaxSteps = 200
+	in.Evaluator().MaxAlloc = 1 << 10
+
+	if _, err := in.Eval(`let f = fn(x) { x + 1 }; let g = fn(s) { s + "!" }; let loop = fn() { while (true) {} }`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// 上限は呼び出しごとに適用されるため、繰り返し呼び出しても超過しない。
+	for i := 0; i < 100; i++ {
+		obj, err := in.Call("f", i)
+		if err != nil {
+			t.Fatalf("call %d: unexpected error: %s", i, err)
+		}
+		if FromObject(obj) != int64(i+1) {
+			t.Errorf("call %d: wrong result. got=%s", i, obj.Inspect())
+		}
+
+		if _, err := in.Call("g", "abcdefghijklmnopqrstuvwxyz"); err != nil {
+			t.Fatalf("call %d: unexpected error: %s", i, err)
+		}
+	}
+
+	var errObj *object.Error
+	if _, err := in.Call("loop"); !errors.As(err, &errObj) || errObj.Kind != object.StepLimitErrorKind {
+		t.Errorf("expected step limit error. got=%v", err)
+	}
+
+	in.Evaluator().MaxSteps = 0
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := in.CallContext(ctx, "loop"); !errors.As(err, &errObj) || errObj.Kind != object.CanceledErrorKind {
+		t.Errorf("expected canceled error. got=%v", err)
+	}
+}
+
+func TestReentrantCall(t *testing.T) {
+	in := New()
+	in.Evaluator().MaxSteps = 1000
+
+	if err := in.RegisterFunction("cb", func() error {
+		_, err := in.Call("f")
+		return err
+	}); err != nil {
+		t.Fatalf("RegisterFunction: %s", err)
+	}
+
+	p, err := in.Compile("", `let f = fn() { 1 }; let i = 0; while (i < 2000) { cb(); i = i + 1 }`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// 登録した関数の中からの呼び出しは外側の実行の一部として数えるため、上限を超える。
+	var errObj *object.Error
+	if _, err := in.Run(p); !errors.As(err, &errObj) || errObj.Kind != object.StepLimitErrorKind {
+		t.Errorf("expected step limit error. got=%v", err)
+	}
+
+	// 登録した関数の中からの呼び出しの後も、外側のコンテキストによる中断を行う。
+	in.Evaluator().MaxSteps = 0
+	p, err = in.Compile("", `cb(); while (true) {}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := in.RunContext(ctx, p); !errors.As(err, &errObj) || errObj.Kind != object.CanceledErrorKind {
+		t.Errorf("expected canceled error. got=%v", err)
+	}
+
+	// 呼び出しが終わった後は、上限を次の実行で数え直す。
+	in.Evaluator().MaxSteps = 1000
+	if _, err := in.Call("f"); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
@@ -1,60 +1,150 @@
 package lexer
 
-import "monkey/token"
+import (
+	"monkey/token"
+	"unicode"
+	"unicode/utf8"
+)
 
 // Lexer 字句解析器
 type Lexer struct {
+	filename     string // 解析対象のファイル名
 	input        string // 解析対象となる文字列
-	position     int    // 入力における現在の位置(現在の文字を指し示す)
-	readPosition int    // これから読み込む位置(現在の文字の次)
-	ch           byte   // 現在検査中の文字
+	position     int    // 入力における現在のバイト位置(現在の文字を指し示す)
+	readPosition int    // これから読み込むバイト位置(現在の文字の次)
+	ch           rune   // 現在検査中の文字
+	line         int    // 現在の文字の行番号
+	column       int    // 現在の文字の列番号(文字単位)
 }
 
 // New 字句解析器を生成する
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewWithFilename("", input)
+}
+
+// NewWithFilename ファイル名を指定して字句解析器を生成する。
+// ファイル名はトークンの位置情報に含まれる。
+func NewWithFilename(filename, input string) *Lexer {
+	l := &Lexer{filename: filename, input: input, line: 1}
 	l.readChar()
 	return l
 }
 
-// NextToken 現在検査中の文字 l.ch を見てその文字が何であるかに応じてトークンを返す。
-// トークンを返す前に入力のポインタを進めて、次に NextToken() を呼んだときに l.ch フィールドが更新されているようにする。
+// NextToken 空白とコメントを読み飛ばして次のトークンを返す。
+// 読み飛ばしたコメントはトークンの Comments に保持される。
+// 閉じられていないブロックコメントは、コメント全体をリテラルとする ILLEGAL トークンとなる。
 func (l *Lexer) NextToken() token.Token {
+	comments, terminated := l.skipTrivia()
+	if !terminated {
+		unterminated := comments[len(comments)-1]
+		return token.Token{
+			Type:     token.Illegal,
+			Literal:  unterminated.Text,
+			Pos:      unterminated.Pos,
+			Comments: comments[:len(comments)-1],
+		}
+	}
+
+	tok := l.readToken()
+	tok.Comments = comments
+	return tok
+}
+
+// readToken 現在検査中の文字 l.ch を見てその文字が何であるかに応じてトークンを返す。
+// トークンを返す前に入力のポインタを進めて、次に NextToken() を呼んだときに l.ch フィールドが更新されているようにする。
+func (l *Lexer) readToken() token.Token {
 	var tok token.Token
 
-	l.skipWhitespace()
+	pos := l.currentPosition()
 
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
-			ch := l.ch
-			l.readChar()
-			literal := string(ch) + string(l.ch)
-			tok = token.Token{Type: token.Eq, Literal: literal}
+			tok = l.readTwoCharToken(token.Eq)
 		} else {
 			tok = newToken(token.Assign, l.ch)
 		}
 	case '+':
-		tok = newToken(token.Plus, l.ch)
+		if l.peekChar() == '=' {
+			tok = l.readTwoCharToken(token.PlusAssign)
+		} else {
+			tok = newToken(token.Plus, l.ch)
+		}
 	case '-':
-		tok = newToken(token.Minus, l.ch)
+		if l.peekChar() == '=' {
+			tok = l.readTwoCharToken(token.MinusAssign)
+		} else {
+			tok = newToken(token.Minus, l.ch)
+		}
 	case '!':
 		if l.peekChar() == '=' {
-			ch := l.ch
-			l.readChar()
-			literal := string(ch) + string(l.ch)
-			tok = token.Token{Type: token.NotEq, Literal: literal}
+			tok = l.readTwoCharToken(token.NotEq)
 		} else {
 			tok = newToken(token.Bang, l.ch)
 		}
 	case '/':
-		tok = newToken(token.Slash, l.ch)
+		if l.peekChar() == '=' {
+			tok = l.readTwoCharToken(token.SlashAssign)
+		} else {
+			tok = newToken(token.Slash, l.ch)
+		}
 	case '*':
-		tok = newToken(token.Asterisk, l.ch)
+		if l.peekChar() == '=' {
+			tok = l.readTwoCharToken(token.AsteriskAssign)
+		} else {
+			tok = newToken(token.Asterisk, l.ch)
+		}
+	case '%':
+		tok = newToken(token.Percent, l.ch)
 	case '<':
-		tok = newToken(token.Lt, l.ch)
+		switch l.peekChar() {
+		case '=':
+			tok = l.readTwoCharToken(token.LtEq)
+		case '<':
+			tok = l.readTwoCharToken(token.ShiftLeft)
+		default:
+			tok = newToken(token.Lt, l.ch)
+		}
 	case '>':
-		tok = newToken(token.Gt, l.ch)
+		switch l.peekChar() {
+		case '=':
+			tok = l.readTwoCharToken(token.GtEq)
+		case '>':
+			tok = l.readTwoCharToken(token.ShiftRight)
+		default:
+			tok = newToken(token.Gt, l.ch)
+		}
+	case '&':
+		if l.peekChar() == '&' {
+			tok = l.readTwoCharToken(token.And)
+		} else {
+			tok = newToken(token.Ampersand, l.ch)
+		}
+	case '|':
+		if l.peekChar() == '|' {
+			tok = l.readTwoCharToken(token.Or)
+		} else {
+			tok = newToken(token.Pipe, l.ch)
+		}
+	case '^':
+		tok = newToken(token.Caret, l.ch)
+	case '?':
+		switch l.peekChar() {
+		case '?':
+			tok = l.readTwoCharToken(token.NullCoalesce)
+		case '[':
+			tok = l.readTwoCharToken(token.OptionalLbracket)
+		default:
+			tok = newToken(token.Illegal, l.ch)
+		}
+	case '.':
+		if l.peekChar() == '.' && l.peekCharAt(2) == '.' {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.Ellipsis, Literal: "..."}
+		} else {
+			tok = newToken(token.Illegal, l.ch)
+		}
 	case ';':
 		tok = newToken(token.Semicolon, l.ch)
 	case ':':
@@ -70,8 +160,14 @@ func (l *Lexer) NextToken() token.Token {
 	case ')':
 		tok = newToken(token.Rparen, l.ch)
 	case '"':
-		tok.Type = token.String
-		tok.Literal = l.readString()
+		raw := l.readString()
+		if value, err := Unquote(raw); err != nil {
+			tok.Type = token.Illegal
+			tok.Literal = raw
+		} else {
+			tok.Type = token.String
+			tok.Literal = value
+		}
 	case '[':
 		tok = newToken(token.Lbracket, l.ch)
 	case ']':
@@ -83,77 +179,213 @@ func (l *Lexer) NextToken() token.Token {
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Pos = pos
 			return tok
 		} else if isDigit(l.ch) {
-			tok.Type = token.Int
-			tok.Literal = l.readNumber()
+			tok.Type, tok.Literal = l.readNumber()
+			tok.Pos = pos
 			return tok
 		}
 		tok = newToken(token.Illegal, l.ch)
 	}
 
 	l.readChar()
+	tok.Pos = pos
 	return tok
 }
 
+// readTwoCharToken 現在の文字と次の文字からなる2文字のトークンを読み込む。
+func (l *Lexer) readTwoCharToken(tokenType token.Type) token.Token {
+	ch := l.ch
+	l.readChar()
+	literal := string(ch) + string(l.ch)
+	return token.Token{Type: tokenType, Literal: literal}
+}
+
+// skipTrivia 空白とコメントを読み飛ばし、読み飛ばしたコメントを返却する。
+// 閉じられていないブロックコメントがあった場合は、それを末尾に含めて false を返却する。
+func (l *Lexer) skipTrivia() ([]token.Comment, bool) {
+	var comments []token.Comment
+
+	for {
+		l.skipWhitespace()
+
+		switch {
+		case l.ch == '/' && l.peekChar() == '/':
+			comments = append(comments, l.readLineComment())
+		case l.ch == '/' && l.peekChar() == '*':
+			comment, ok := l.readBlockComment()
+			comments = append(comments, comment)
+			if !ok {
+				return comments, false
+			}
+		default:
+			return comments, true
+		}
+	}
+}
+
 func (l *Lexer) skipWhitespace() {
 	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
 		l.readChar()
 	}
 }
 
+// readLineComment "//" から行末までを読み込む。改行文字はコメントに含めない。
+func (l *Lexer) readLineComment() token.Comment {
+	pos := l.currentPosition()
+	for l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
+	return token.Comment{Text: l.input[pos.Offset:l.position], Pos: pos}
+}
+
+// readBlockComment "/*" から "*/" までを読み込む。入れ子には対応しない。
+// "*/" が見つからないまま入力の末尾に達した場合は false を返却する。
+func (l *Lexer) readBlockComment() (token.Comment, bool) {
+	pos := l.currentPosition()
+
+	// "/*" を読み飛ばす。
+	l.readChar()
+	l.readChar()
+
+	for {
+		if l.ch == 0 {
+			return token.Comment{Text: l.input[pos.Offset:l.position], Pos: pos}, false
+		}
+		if l.ch == '*' && l.peekChar() == '/' {
+			l.readChar()
+			l.readChar()
+			return token.Comment{Text: l.input[pos.Offset:l.position], Pos: pos}, true
+		}
+		l.readChar()
+	}
+}
+
+// readChar 次の文字を読み込む。入力はUTF-8として1文字(rune)ずつ読み込む。
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+	if l.readPosition <= len(l.input) {
+		l.column++
+	}
+
+	width := 1
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
-		l.ch = l.input[l.readPosition]
+		l.ch, width = utf8.DecodeRuneInString(l.input[l.readPosition:])
 	}
 	l.position = l.readPosition
-	l.readPosition++
+	l.readPosition += width
 }
 
-func (l *Lexer) peekChar() byte {
-	if l.readPosition >= len(l.input) {
+func (l *Lexer) currentPosition() token.Position {
+	return token.Position{
+		Filename: l.filename,
+		Offset:   l.position,
+		Line:     l.line,
+		Column:   l.column,
+	}
+}
+
+func (l *Lexer) peekChar() rune {
+	return l.peekCharAt(1)
+}
+
+// peekCharAt 現在の文字から n 文字先の文字を返却する。
+func (l *Lexer) peekCharAt(n int) rune {
+	pos := l.readPosition
+	for i := 1; i < n && pos < len(l.input); i++ {
+		_, width := utf8.DecodeRuneInString(l.input[pos:])
+		pos += width
+	}
+	if pos >= len(l.input) {
 		return 0
 	}
-	return l.input[l.readPosition]
+	ch, _ := utf8.DecodeRuneInString(l.input[pos:])
+	return ch
 }
 
+// readIdentifier 識別子を読み込む。識別子は文字で始まり、2文字目以降には数字も使用できる。
 func (l *Lexer) readIdentifier() string {
 	position := l.position
-	for isLetter(l.ch) {
+	for isLetter(l.ch) || unicode.IsDigit(l.ch) {
 		l.readChar()
 	}
 	return l.input[position:l.position]
 }
 
-func (l *Lexer) readNumber() string {
+// readNumber 整数または浮動小数点数を読み込む。
+// 小数点の後には数字が続く必要があり、指数部(e または E)には符号を付けることができる。
+func (l *Lexer) readNumber() (token.Type, string) {
 	position := l.position
+	tokenType := token.Type(token.Int)
+
+	l.readDigits()
+
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		tokenType = token.Float
+		l.readChar()
+		l.readDigits()
+	}
+
+	if l.ch == 'e' || l.ch == 'E' {
+		next := l.peekChar()
+		if isDigit(next) || (next == '+' || next == '-') && isDigit(l.peekCharAt(2)) {
+			tokenType = token.Float
+			l.readChar()
+			if l.ch == '+' || l.ch == '-' {
+				l.readChar()
+			}
+			l.readDigits()
+		}
+	}
+
+	return tokenType, l.input[position:l.position]
+}
+
+func (l *Lexer) readDigits() {
 	for isDigit(l.ch) {
 		l.readChar()
 	}
-	return l.input[position:l.position]
 }
 
+// readString 文字列リテラルの原文を、前後のダブルクォートを含めて読み込む。
+// 閉じるダブルクォートが存在しない場合は、入力の末尾までを返却する。
 func (l *Lexer) readString() string {
-	position := l.position + 1
+	position := l.position
 	for {
 		l.readChar()
-		if l.ch == '"' || l.ch == 0 {
+		if l.ch == '\\' {
+			l.readChar()
+			if l.ch == 0 {
+				break
+			}
+			continue
+		}
+		if l.ch == '"' {
+			return l.input[position : l.position+1]
+		}
+		if l.ch == 0 {
 			break
 		}
 	}
 	return l.input[position:l.position]
 }
 
-func isLetter(ch byte) bool {
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+// isLetter 識別子に使用できる文字かを返却する。Unicodeの文字とアンダースコアを受け付ける。
+func isLetter(ch rune) bool {
+	return unicode.IsLetter(ch) || ch == '_'
 }
 
-func isDigit(ch byte) bool {
+// isDigit 数値リテラルに使用できる数字かを返却する。数値リテラルにはASCIIの数字のみ使用できる。
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
 
-func newToken(tokenType token.Type, ch byte) token.Token {
+func newToken(tokenType token.Type, ch rune) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 }
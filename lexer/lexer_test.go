@@ -14,7 +14,7 @@ func TestNextToken(t *testing.T) {
 	};
 
 	let result = add(five, ten);
-	!-/*5;
+	!-/ *5;
 	5 < 10 > 5;
 
 	if (5 < 10) {
@@ -29,6 +29,12 @@ func TestNextToken(t *testing.T) {
   "foo bar"
 	[1, 2];
 	{"foo": "bar"}
+	x += 1; x -= 1; x *= 2; x /= 2;
+	while for in break continue
+	a <= b >= c % d && e || f & g | h ^ i << j >> k
+	null ?? h?["a"]
+	throw try catch finally
+	fn(...rest)
 	`
 
 	tests := []struct {
@@ -121,6 +127,150 @@ func TestNextToken(t *testing.T) {
 		{token.Colon, ":"},
 		{token.String, "bar"},
 		{token.Rbrace, "}"},
+		{token.Ident, "x"},
+		{token.PlusAssign, "+="},
+		{token.Int, "1"},
+		{token.Semicolon, ";"},
+		{token.Ident, "x"},
+		{token.MinusAssign, "-="},
+		{token.Int, "1"},
+		{token.Semicolon, ";"},
+		{token.Ident, "x"},
+		{token.AsteriskAssign, "*="},
+		{token.Int, "2"},
+		{token.Semicolon, ";"},
+		{token.Ident, "x"},
+		{token.SlashAssign, "/="},
+		{token.Int, "2"},
+		{token.Semicolon, ";"},
+		{token.While, "while"},
+		{token.For, "for"},
+		{token.In, "in"},
+		{token.Break, "break"},
+		{token.Continue, "continue"},
+		{token.Ident, "a"},
+		{token.LtEq, "<="},
+		{token.Ident, "b"},
+		{token.GtEq, ">="},
+		{token.Ident, "c"},
+		{token.Percent, "%"},
+		{token.Ident, "d"},
+		{token.And, "&&"},
+		{token.Ident, "e"},
+		{token.Or, "||"},
+		{token.Ident, "f"},
+		{token.Ampersand, "&"},
+		{token.Ident, "g"},
+		{token.Pipe, "|"},
+		{token.Ident, "h"},
+		{token.Caret, "^"},
+		{token.Ident, "i"},
+		{token.ShiftLeft, "<<"},
+		{token.Ident, "j"},
+		{token.ShiftRight, ">>"},
+		{token.Ident, "k"},
+		{token.Null, "null"},
+		{token.NullCoalesce, "??"},
+		{token.Ident, "h"},
+		{token.OptionalLbracket, "?["},
+		{token.String, "a"},
+		{token.Rbracket, "]"},
+		{token.Throw, "throw"},
+		{token.Try, "try"},
+		{token.Catch, "catch"},
+		{token.Finally, "finally"},
+		{token.Function, "fn"},
+		{token.Lparen, "("},
+		{token.Ellipsis, "..."},
+		{token.Ident, "rest"},
+		{token.Rparen, ")"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	input := `let x = 5;
+  x + "foo";
+`
+
+	tests := []struct {
+		expectedType   token.Type
+		expectedLine   int
+		expectedColumn int
+		expectedOffset int
+	}{
+		{token.Let, 1, 1, 0},
+		{token.Ident, 1, 5, 4},
+		{token.Assign, 1, 7, 6},
+		{token.Int, 1, 9, 8},
+		{token.Semicolon, 1, 10, 9},
+		{token.Ident, 2, 3, 13},
+		{token.Plus, 2, 5, 15},
+		{token.String, 2, 7, 17},
+		{token.Semicolon, 2, 12, 22},
+		{token.EOF, 3, 1, 24},
+	}
+
+	l := NewWithFilename("test.monkey", input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Pos.Filename != "test.monkey" {
+			t.Errorf("tests[%d] - filename wrong. expected=%q, got=%q", i, "test.monkey", tok.Pos.Filename)
+		}
+
+		if tok.Pos.Line != tt.expectedLine || tok.Pos.Column != tt.expectedColumn {
+			t.Errorf("tests[%d] - position wrong. expected=%d:%d, got=%d:%d",
+				i, tt.expectedLine, tt.expectedColumn, tok.Pos.Line, tok.Pos.Column)
+		}
+
+		if tok.Pos.Offset != tt.expectedOffset {
+			t.Errorf("tests[%d] - offset wrong. expected=%d, got=%d", i, tt.expectedOffset, tok.Pos.Offset)
+		}
+	}
+}
+
+func TestNumberTokens(t *testing.T) {
+	input := `5 3.14 0.5 1e9 2.5E-3 6e+2 7.e 8e x1.5 ..5`
+
+	tests := []struct {
+		expectedType    token.Type
+		expectedLiteral string
+	}{
+		{token.Int, "5"},
+		{token.Float, "3.14"},
+		{token.Float, "0.5"},
+		{token.Float, "1e9"},
+		{token.Float, "2.5E-3"},
+		{token.Float, "6e+2"},
+		{token.Int, "7"},
+		{token.Illegal, "."},
+		{token.Ident, "e"},
+		{token.Int, "8"},
+		{token.Ident, "e"},
+		{token.Ident, "x1"},
+		{token.Illegal, "."},
+		{token.Int, "5"},
+		{token.Illegal, "."},
+		{token.Illegal, "."},
+		{token.Int, "5"},
 		{token.EOF, ""},
 	}
 
@@ -137,3 +287,130 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+func TestComments(t *testing.T) {
+	input := `// header
+let x = 5; // trailing
+/* block
+   comment */ x /* inline */ / 2;
+/* unterminated`
+
+	tests := []struct {
+		expectedType     token.Type
+		expectedLiteral  string
+		expectedComments []token.Comment
+	}{
+		{token.Let, "let", []token.Comment{{Text: "// header", Pos: token.Position{Offset: 0, Line: 1, Column: 1}}}},
+		{token.Ident, "x", nil},
+		{token.Assign, "=", nil},
+		{token.Int, "5", nil},
+		{token.Semicolon, ";", nil},
+		{token.Ident, "x", []token.Comment{
+			{Text: "// trailing", Pos: token.Position{Offset: 21, Line: 2, Column: 12}},
+			{Text: "/* block\n   comment */", Pos: token.Position{Offset: 33, Line: 3, Column: 1}},
+		}},
+		{token.Slash, "/", []token.Comment{{Text: "/* inline */", Pos: token.Position{Offset: 58, Line: 4, Column: 17}}}},
+		{token.Int, "2", nil},
+		{token.Semicolon, ";", nil},
+		{token.Illegal, "/* unterminated", nil},
+		{token.EOF, "", nil},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if len(tok.Comments) != len(tt.expectedComments) {
+			t.Fatalf("tests[%d] - comments wrong. expected=%+v, got=%+v", i, tt.expectedComments, tok.Comments)
+		}
+
+		for j, comment := range tt.expectedComments {
+			if tok.Comments[j] != comment {
+				t.Errorf("tests[%d] - comment[%d] wrong. expected=%+v, got=%+v", i, j, comment, tok.Comments[j])
+			}
+		}
+	}
+}
+
+func TestStringTokens(t *testing.T) {
+	input := `"a\tb" "\u{65E5}\"" "bad\x" "open`
+
+	tests := []struct {
+		expectedType    token.Type
+		expectedLiteral string
+	}{
+		{token.String, "a\tb"},
+		{token.String, "日\""},
+		{token.Illegal, `"bad\x"`},
+		{token.Illegal, `"open`},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestUnicodeIdentifiers(t *testing.T) {
+	input := `let 合計 = 値1 + _x2;
+合計 "é" ü`
+
+	tests := []struct {
+		expectedType    token.Type
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+		expectedOffset  int
+	}{
+		{token.Let, "let", 1, 1, 0},
+		{token.Ident, "合計", 1, 5, 4},
+		{token.Assign, "=", 1, 8, 11},
+		{token.Ident, "値1", 1, 10, 13},
+		{token.Plus, "+", 1, 13, 18},
+		{token.Ident, "_x2", 1, 15, 20},
+		{token.Semicolon, ";", 1, 18, 23},
+		{token.Ident, "合計", 2, 1, 25},
+		{token.String, "é", 2, 4, 32},
+		{token.Ident, "ü", 2, 8, 37},
+		{token.EOF, "", 2, 9, 39},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Pos.Line != tt.expectedLine || tok.Pos.Column != tt.expectedColumn {
+			t.Errorf("tests[%d] - position wrong. expected=%d:%d, got=%d:%d",
+				i, tt.expectedLine, tt.expectedColumn, tok.Pos.Line, tok.Pos.Column)
+		}
+
+		if tok.Pos.Offset != tt.expectedOffset {
+			t.Errorf("tests[%d] - offset wrong. expected=%d, got=%d", i, tt.expectedOffset, tok.Pos.Offset)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package lexer
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrUnterminatedString 文字列が閉じられていないことを表すエラー
+var ErrUnterminatedString = errors.New("unterminated string")
+
+// Unquote ダブルクォートで囲まれた文字列リテラルの原文から、エスケープシーケンスを解釈した値を返却する。
+// 使用できるエスケープシーケンスは \n \t \r \" \\ と、16進数でコードポイントを指定する \u{...} である。
+func Unquote(raw string) (string, error) {
+	if !strings.HasPrefix(raw, `"`) {
+		return "", fmt.Errorf("string literal must start with '\"': %s", raw)
+	}
+
+	var out strings.Builder
+
+	for i := 1; i < len(raw); i++ {
+		ch := raw[i]
+
+		switch ch {
+		case '"':
+			if i != len(raw)-1 {
+				return "", fmt.Errorf("unexpected characters after string literal: %s", raw[i+1:])
+			}
+			return out.String(), nil
+		case '\\':
+			i++
+			if i >= len(raw) {
+				return "", ErrUnterminatedString
+			}
+
+			switch raw[i] {
+			case 'n':
+				out.WriteByte('\n')
+			case 't':
+				out.WriteByte('\t')
+			case 'r':
+				out.WriteByte('\r')
+			case '"':
+				out.WriteByte('"')
+			case '\\':
+				out.WriteByte('\\')
+			case 'u':
+				r, n, err := unquoteUnicode(raw[i+1:])
+				if err != nil {
+					return "", err
+				}
+				out.WriteRune(r)
+				i += n
+			default:
+				return "", fmt.Errorf("invalid escape sequence: \\%c", raw[i])
+			}
+		default:
+			out.WriteByte(ch)
+		}
+	}
+
+	return "", ErrUnterminatedString
+}
+
+// unquoteUnicode \u に続く "{...}" を解釈し、コードポイントと読み込んだバイト数を返却する。
+func unquoteUnicode(s string) (rune, int, error) {
+	end := strings.IndexByte(s, '}')
+	if !strings.HasPrefix(s, "{") || end < 0 {
+		return 0, 0, errors.New(`invalid unicode escape: \u must be followed by {hex digits}`)
+	}
+
+	digits := s[1:end]
+	if len(digits) == 0 || len(digits) > 6 {
+		return 0, 0, fmt.Errorf("invalid unicode escape: \\u{%s}", digits)
+	}
+
+	code, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil || !utf8.ValidRune(rune(code)) {
+		return 0, 0, fmt.Errorf("invalid unicode escape: \\u{%s}", digits)
+	}
+
+	return rune(code), end + 1, nil
+}
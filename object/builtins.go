@@ -0,0 +1,181 @@
+package object
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// Builtins 組み込み関数の一覧。
+// コンパイラは添字で組み込み関数を参照するため、要素の順序を変更してはならない。
+var Builtins = []struct {
+	Name    string
+	Builtin *Builtin
+}{
+	{
+		"len",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return NewError(ArgumentErrorKind, "wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *Array:
+				return &Integer{Value: int64(len(arg.Elements))}
+			case *String:
+				return &Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+			default:
+				return NewError(TypeErrorKind, "argument to `len` not supported, got %s", args[0].Type())
+			}
+		},
+		},
+	},
+	{
+		"puts",
+		&Builtin{Fn: func(args ...Object) Object {
+			for _, arg := range args {
+				fmt.Println(arg.Inspect())
+			}
+
+			return nil
+		},
+		},
+	},
+	{
+		"first",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return NewError(ArgumentErrorKind, "wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			if args[0].Type() != ArrayObj {
+				return NewError(TypeErrorKind, "argument to `first` must be ARRAY, got %s",
+					args[0].Type())
+			}
+
+			arr := args[0].(*Array)
+			if len(arr.Elements) > 0 {
+				return arr.Elements[0]
+			}
+
+			return nil
+		},
+		},
+	},
+	{
+		"last",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return NewError(ArgumentErrorKind, "wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			if args[0].Type() != ArrayObj {
+				return NewError(TypeErrorKind, "argument to `last` must be ARRAY, got %s",
+					args[0].Type())
+			}
+
+			arr := args[0].(*Array)
+			length := len(arr.Elements)
+			if length > 0 {
+				return arr.Elements[length-1]
+			}
+
+			return nil
+		},
+		},
+	},
+	{
+		"rest",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return NewError(ArgumentErrorKind, "wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			if args[0].Type() != ArrayObj {
+				return NewError(TypeErrorKind, "argument to `rest` must be ARRAY, got %s",
+					args[0].Type())
+			}
+
+			arr := args[0].(*Array)
+			length := len(arr.Elements)
+			if length > 0 {
+				newElements := make([]Object, length-1)
+				copy(newElements, arr.Elements[1:length])
+				return &Array{Elements: newElements}
+			}
+
+			return nil
+		},
+		},
+	},
+	{
+		"push",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 2 {
+				return NewError(ArgumentErrorKind, "wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			if args[0].Type() != ArrayObj {
+				return NewError(TypeErrorKind, "argument to `push` must be ARRAY, got %s",
+					args[0].Type())
+			}
+
+			arr := args[0].(*Array)
+			length := len(arr.Elements)
+
+			newElements := make([]Object, length+1)
+			copy(newElements, arr.Elements)
+			newElements[length] = args[1]
+
+			return &Array{Elements: newElements}
+		},
+		},
+	},
+	{
+		"keys",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return NewError(ArgumentErrorKind, "wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			if args[0].Type() != HashObj {
+				return NewError(TypeErrorKind, "argument to `keys` must be HASH, got %s",
+					args[0].Type())
+			}
+
+			return &Array{Elements: args[0].(*Hash).Keys()}
+		},
+		},
+	},
+	{
+		"values",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return NewError(ArgumentErrorKind, "wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			if args[0].Type() != HashObj {
+				return NewError(TypeErrorKind, "argument to `values` must be HASH, got %s",
+					args[0].Type())
+			}
+
+			pairs := args[0].(*Hash).Pairs()
+			values := make([]Object, len(pairs))
+			for i, pair := range pairs {
+				values[i] = pair.Value
+			}
+
+			return &Array{Elements: values}
+		},
+		},
+	},
+}
+
+// GetBuiltinByName 名前に対応する組み込み関数を返却する。存在しない場合は nil を返却する。
+func GetBuiltinByName(name string) *Builtin {
+	for _, def := range Builtins {
+		if def.Name == name {
+			return def.Builtin
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package object
+
+import (
+	"math"
+	"math/big"
+)
+
+// MaxShiftCount 多倍長整数のシフト演算で指定できるシフト量の上限
+const MaxShiftCount = math.MaxInt32
+
+// NewBigInt 多倍長整数からオブジェクトを生成する。
+// 値が int64 に収まる場合は Integer を、収まらない場合は BigInt を返却する。
+func NewBigInt(v *big.Int) Object {
+	if v.IsInt64() {
+		return &Integer{Value: v.Int64()}
+	}
+	return &BigInt{Value: v}
+}
+
+// ToBigInt Integer または BigInt の値を多倍長整数として返却する。それ以外の場合は nil を返却する。
+func ToBigInt(obj Object) *big.Int {
+	switch obj := obj.(type) {
+	case *Integer:
+		return big.NewInt(obj.Value)
+	case *BigInt:
+		return obj.Value
+	default:
+		return nil
+	}
+}
+
+// IntArithmetic 整数の +, -, *, << を行い、結果と共にオーバーフローしなかったかを返却する。
+// オーバーフローした場合の結果は、Goの演算と同様に循環した値となる。<< のシフト量は0以上である必要がある。
+func IntArithmetic(operator string, left, right int64) (int64, bool) {
+	switch operator {
+	case "+":
+		result := left + right
+		// 同じ符号の値同士の加算で結果の符号が変わった場合にオーバーフローしている。
+		return result, (left^result)&(right^result) >= 0
+	case "-":
+		result := left - right
+		// 異なる符号の値同士の減算で結果の符号が左辺と変わった場合にオーバーフローしている。
+		return result, (left^right)&(left^result) >= 0
+	case "*":
+		result := left * right
+		if left == 0 || right == 0 {
+			return result, true
+		}
+		// math.MinInt64 * -1 は循環して math.MinInt64 となり、除算による検算をすり抜ける。
+		if right == -1 && left == math.MinInt64 {
+			return result, false
+		}
+		return result, result/right == left
+	case "<<":
+		result := left << uint64(right)
+		// 算術右シフトで元の値に戻らない場合は、はみ出したビットがある。
+		return result, result>>uint64(right) == left
+	default:
+		return 0, false
+	}
+}
+
+// BigIntArithmetic 多倍長整数の算術演算とビット演算を行う。
+// 除算と剰余はGoの整数演算と同様に0方向へ切り捨て、右シフトは算術シフトとなる。
+// 除数が0の場合、シフト量が負または大きすぎる場合、未知の演算子の場合は nil を返却する。
+func BigIntArithmetic(operator string, left, right *big.Int) Object {
+	result := new(big.Int)
+
+	switch operator {
+	case "+":
+		result.Add(left, right)
+	case "-":
+		result.Sub(left, right)
+	case "*":
+		result.Mul(left, right)
+	case "/", "%":
+		if right.Sign() == 0 {
+			return nil
+		}
+		if operator == "/" {
+			result.Quo(left, right)
+		} else {
+			result.Rem(left, right)
+		}
+	case "&":
+		result.And(left, right)
+	case "|":
+		result.Or(left, right)
+	case "^":
+		result.Xor(left, right)
+	case "<<", ">>":
+		if right.Sign() < 0 || !right.IsInt64() || right.Int64() > MaxShiftCount {
+			return nil
+		}
+		if operator == "<<" {
+			result.Lsh(left, uint(right.Int64()))
+		} else {
+			result.Rsh(left, uint(right.Int64()))
+		}
+	default:
+		return nil
+	}
+
+	return NewBigInt(result)
+}
@@ -2,9 +2,15 @@ package object
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"hash/fnv"
+	"math"
+	"math/big"
 	"monkey/ast"
+	"monkey/code"
+	"monkey/token"
+	"strconv"
 	"strings"
 )
 
@@ -19,9 +25,15 @@ const (
 	NullObj = "NULL"
 	// ErrorObj ERROR
 	ErrorObj = "ERROR"
+	// ExceptionObj catch で捕捉したエラー
+	ExceptionObj = "EXCEPTION"
 
 	// IntegerObj 整数値
 	IntegerObj = "INTEGER"
+	// BigIntObj 多倍長整数
+	BigIntObj = "BIGINT"
+	// FloatObj 浮動小数点数
+	FloatObj = "FLOAT"
 	// BooleanObj 真偽値
 	BooleanObj = "BOOLEAN"
 	//StringObj 文字列
@@ -29,6 +41,10 @@ const (
 
 	// ReturnValueObj 戻り値オブジェクト
 	ReturnValueObj = "RETURN_VALUE"
+	// BreakObj break によるループ脱出の合図
+	BreakObj = "BREAK"
+	// ContinueObj continue によるループ継続の合図
+	ContinueObj = "CONTINUE"
 
 	// FunctionObj 関数オブジェクト
 	FunctionObj = "FUNCTION"
@@ -36,9 +52,16 @@ const (
 	BuiltinObj = "BUILTIN"
 	// HashObj ハッシュマップ
 	HashObj = "HASH"
+	// ModuleObj import で読み込んだモジュール
+	ModuleObj = "MODULE"
 
 	// ArrayObj 配列
 	ArrayObj = "ARRAY"
+
+	// CompiledFunctionObj コンパイル済み関数
+	CompiledFunctionObj = "COMPILED_FUNCTION_OBJ"
+	// ClosureObj クロージャ
+	ClosureObj = "CLOSURE"
 )
 
 // HashKey ハッシュキー
@@ -47,9 +70,44 @@ type HashKey struct {
 	Value uint64
 }
 
-// Hashable ハッシュテーブル
+// Hashable ハッシュのキーとして使用できるオブジェクト
 type Hashable interface {
+	Object
+	// HashKey キーのハッシュ値を返却する。等しいキーは同じハッシュ値を返却しなければならない。
 	HashKey() HashKey
+	// KeyEquals other と等しいキーかを返却する。ハッシュ値が衝突したキーの区別に使用する。
+	KeyEquals(other Hashable) bool
+}
+
+// AsHashable オブジェクトがハッシュのキーとして使用できる場合は Hashable として返却する。
+// 配列は全ての要素がキーとして使用できる場合に限りキーとして使用できる。自身を含む配列は使用できない。
+func AsHashable(obj Object) (Hashable, bool) {
+	if !isHashable(obj, map[*Array]bool{}) {
+		return nil, false
+	}
+	return obj.(Hashable), true
+}
+
+// isHashable オブジェクトがキーとして使用できるかを返却する。visiting は検査中の配列の集合。
+func isHashable(obj Object, visiting map[*Array]bool) bool {
+	arr, ok := obj.(*Array)
+	if !ok {
+		_, ok := obj.(Hashable)
+		return ok
+	}
+
+	if visiting[arr] {
+		return false
+	}
+	visiting[arr] = true
+	defer delete(visiting, arr)
+
+	for _, el := range arr.Elements {
+		if !isHashable(el, visiting) {
+			return false
+		}
+	}
+	return true
 }
 
 // Object オブジェクト
@@ -74,6 +132,108 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
+// KeyEquals other と等しいキーかを返却する。
+// 値が整数の浮動小数点数とは、数値として等しい場合に等しいキーとする。
+func (i *Integer) KeyEquals(other Hashable) bool {
+	switch o := other.(type) {
+	case *Integer:
+		return o.Value == i.Value
+	case *Float:
+		return o.KeyEquals(i)
+	default:
+		return false
+	}
+}
+
+// BigInt int64 に収まらない整数
+type BigInt struct {
+	Value *big.Int
+}
+
+// Type オブジェクトのタイプを返却する。
+func (bi *BigInt) Type() Type { return BigIntObj }
+
+// Inspect オブジェクトの値を返却する。
+func (bi *BigInt) Inspect() string { return bi.Value.String() }
+
+// HashKey ハッシュキーを取得する。
+func (bi *BigInt) HashKey() HashKey {
+	h := fnv.New64a()
+	if _, ok := h.Write([]byte(bi.Value.String())); ok != nil {
+		panic(ok.Error())
+	}
+
+	return HashKey{Type: bi.Type(), Value: h.Sum64()}
+}
+
+// KeyEquals other と等しいキーかを返却する。
+// 値が整数の浮動小数点数とは、数値として等しい場合に等しいキーとする。
+func (bi *BigInt) KeyEquals(other Hashable) bool {
+	switch o := other.(type) {
+	case *BigInt:
+		return o.Value.Cmp(bi.Value) == 0
+	case *Float:
+		return o.KeyEquals(bi)
+	default:
+		return false
+	}
+}
+
+// Float 浮動小数点数
+type Float struct {
+	Value float64
+}
+
+// Type オブジェクトのタイプを返却する。
+func (f *Float) Type() Type { return FloatObj }
+
+// Inspect オブジェクトの値を返却する。整数値と区別するため、必ず小数点または指数部を含める。
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eEIN") {
+		s += ".0"
+	}
+	return s
+}
+
+// HashKey ハッシュキーを取得する。値が整数の場合は、数値として等しい整数と同じキーとする。
+// 0 と -0 は同じキーとし、NaN は全て同じキーとする。
+func (f *Float) HashKey() HashKey {
+	if n, ok := integralFloat(f.Value); ok {
+		return NewBigInt(n).(Hashable).HashKey()
+	}
+
+	bits := math.Float64bits(f.Value)
+	if math.IsNaN(f.Value) {
+		bits = math.Float64bits(math.NaN())
+	}
+
+	return HashKey{Type: f.Type(), Value: bits}
+}
+
+// KeyEquals other と等しいキーかを返却する。整数とは数値として等しい場合に等しいキーとし、NaN は NaN と等しいキーとする。
+func (f *Float) KeyEquals(other Hashable) bool {
+	switch o := other.(type) {
+	case *Float:
+		return o.Value == f.Value || (math.IsNaN(o.Value) && math.IsNaN(f.Value))
+	case *Integer, *BigInt:
+		n, ok := integralFloat(f.Value)
+		return ok && n.Cmp(ToBigInt(o)) == 0
+	default:
+		return false
+	}
+}
+
+// integralFloat 値が整数の浮動小数点数を多倍長整数に変換する。整数でない場合は false を返却する。
+func integralFloat(f float64) (*big.Int, bool) {
+	if math.IsInf(f, 0) || math.IsNaN(f) || math.Trunc(f) != f {
+		return nil, false
+	}
+
+	n, _ := big.NewFloat(f).Int(nil)
+	return n, true
+}
+
 // Boolean 真偽値
 type Boolean struct {
 	Value bool
@@ -98,6 +258,30 @@ func (b *Boolean) HashKey() HashKey {
 	return HashKey{Type: b.Type(), Value: value}
 }
 
+// KeyEquals other と等しいキーかを返却する。
+func (b *Boolean) KeyEquals(other Hashable) bool {
+	o, ok := other.(*Boolean)
+	return ok && o.Value == b.Value
+}
+
+// 評価器と仮想マシンは null と真偽値を同一性で比較するため、これらの値を生成する場合は以下の値を使用する。
+var (
+	// NullValue null
+	NullValue = &Null{}
+	// True true
+	True = &Boolean{Value: true}
+	// False false
+	False = &Boolean{Value: false}
+)
+
+// NewBoolean 真偽値に対応する True または False を返却する。
+func NewBoolean(b bool) *Boolean {
+	if b {
+		return True
+	}
+	return False
+}
+
 // Null null
 type Null struct{}
 
@@ -118,9 +302,30 @@ func (rv *ReturnValue) Type() Type { return ReturnValueObj }
 // Inspect オブジェクトの値を返却する。
 func (rv *ReturnValue) Inspect() string { return rv.Value.Inspect() }
 
+// Break break によるループ脱出の合図。ReturnValue と同様にループまで伝播する。
+type Break struct{}
+
+// Type オブジェクトのタイプを返却する。
+func (b *Break) Type() Type { return BreakObj }
+
+// Inspect オブジェクトの値を返却する。
+func (b *Break) Inspect() string { return "break" }
+
+// Continue continue によるループ継続の合図。ReturnValue と同様にループまで伝播する。
+type Continue struct{}
+
+// Type オブジェクトのタイプを返却する。
+func (c *Continue) Type() Type { return ContinueObj }
+
+// Inspect オブジェクトの値を返却する。
+func (c *Continue) Inspect() string { return "continue" }
+
 // Function 関数
 type Function struct {
+	Name       string // 関数宣言または let で束縛された名前(無名関数の場合は空文字)
 	Parameters []*ast.Identifier
+	Defaults   []ast.Expression // 仮引数の既定値(ast.FunctionLiteral.Defaults と同じ)
+	Rest       *ast.Identifier  // 残余引数。存在しない場合は nil
 	Body       *ast.BlockStatement
 	Env        *Environment
 }
@@ -133,11 +338,21 @@ func (f *Function) Inspect() string {
 	var out bytes.Buffer
 
 	params := []string{}
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
+	for i, p := range f.Parameters {
+		if f.Defaults != nil && f.Defaults[i] != nil {
+			params = append(params, p.String()+" = "+f.Defaults[i].String())
+		} else {
+			params = append(params, p.String())
+		}
+	}
+	if f.Rest != nil {
+		params = append(params, "..."+f.Rest.String())
 	}
 
 	out.WriteString("fn")
+	if f.Name != "" {
+		out.WriteString(" " + f.Name)
+	}
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") {\n")
@@ -168,16 +383,129 @@ func (s *String) HashKey() HashKey {
 	return HashKey{Type: s.Type(), Value: h.Sum64()}
 }
 
+// KeyEquals other と等しいキーかを返却する。
+func (s *String) KeyEquals(other Hashable) bool {
+	o, ok := other.(*String)
+	return ok && o.Value == s.Value
+}
+
+// エラー種別。catch で捕捉したエラーの "kind" として参照できる。
+const (
+	// ErrorKind 汎用のエラー(throw で送出された値など)
+	ErrorKind = "Error"
+	// TypeErrorKind 型が不正な値に対する演算
+	TypeErrorKind = "TypeError"
+	// ArgumentErrorKind 関数の引数の数が不正
+	ArgumentErrorKind = "ArgumentError"
+	// NameErrorKind 未定義の識別子の参照
+	NameErrorKind = "NameError"
+	// IndexErrorKind 範囲外の添字
+	IndexErrorKind = "IndexError"
+	// ValueErrorKind 型は正しいが値が不正(負のシフト数など)
+	ValueErrorKind = "ValueError"
+	// ZeroDivisionErrorKind ゼロ除算
+	ZeroDivisionErrorKind = "ZeroDivisionError"
+	// OverflowErrorKind 整数演算のオーバーフロー
+	OverflowErrorKind = "OverflowError"
+	// ImportErrorKind モジュールの読み込みの失敗
+	ImportErrorKind = "ImportError"
+	// CanceledErrorKind コンテキストの取り消しまたは期限切れによる評価の中断
+	CanceledErrorKind = "CanceledError"
+	// StepLimitErrorKind 評価するノード数の上限の超過
+	StepLimitErrorKind = "StepLimitError"
+	// CallDepthErrorKind 関数呼び出しの入れ子の深さの上限の超過
+	CallDepthErrorKind = "CallDepthError"
+	// MemoryLimitErrorKind 生成した値の大きさの合計の上限の超過
+	MemoryLimitErrorKind = "MemoryLimitError"
+)
+
+// StackFrame 呼び出し履歴(stack trace)の1フレーム
+type StackFrame struct {
+	Function string         // 関数名(無名関数の場合は空文字)
+	Pos      token.Position // 呼び出し位置
+}
+
+// String "name (file:line:col)" 形式の文字列を返却する。
+func (f StackFrame) String() string {
+	name := f.Function
+	if name == "" {
+		name = "<anonymous>"
+	}
+	return name + " (" + f.Pos.String() + ")"
+}
+
 // Error Error
 type Error struct {
+	Kind    string // エラー種別(ErrorKind, TypeErrorKind など)
 	Message string
+	Pos     token.Position // エラーが発生した式の位置
+	Value   Object         // throw で送出された値(実行時エラーの場合は nil)
+	Stack   []StackFrame   // エラーが伝播した関数呼び出しの履歴(内側から順に格納する)
+}
+
+// NewError 種別とメッセージを指定してエラーを生成する。
+func NewError(kind string, format string, a ...interface{}) *Error {
+	return &Error{Kind: kind, Message: fmt.Sprintf(format, a...)}
 }
 
 // Type オブジェクトのタイプを返却する。
 func (e *Error) Type() Type { return ErrorObj }
 
 // Inspect オブジェクトの値を返却する。
-func (e *Error) Inspect() string { return "ERROR: " + e.Message }
+func (e *Error) Inspect() string {
+	if e.Pos.IsValid() {
+		return "ERROR: " + e.Pos.String() + ": " + e.Message
+	}
+	return "ERROR: " + e.Message
+}
+
+// Error error インタフェースを実装する。Inspect と同じ文字列を返却する。
+func (e *Error) Error() string { return e.Inspect() }
+
+// StackTrace 呼び出し履歴を内側から順に "  at name (file:line:col)" 形式で1行ずつ返却する。
+// 履歴が存在しない場合は空文字を返却する。
+func (e *Error) StackTrace() string {
+	var out strings.Builder
+
+	for _, f := range e.Stack {
+		out.WriteString("  at " + f.String() + "\n")
+	}
+
+	return out.String()
+}
+
+// Exception catch で捕捉したエラー。値として扱うことができ、伝播しない。
+// 添字で "message", "kind", "stack", "value" を参照できる。
+type Exception struct {
+	Err *Error
+}
+
+// Type オブジェクトのタイプを返却する。
+func (e *Exception) Type() Type { return ExceptionObj }
+
+// Inspect オブジェクトの値を返却する。
+func (e *Exception) Inspect() string { return e.Err.Kind + ": " + e.Err.Message }
+
+// Field 名前に対応する属性を返却する。存在しない属性の場合は false を返却する。
+// 実行時エラーには送出された値が存在しないため、"value" は nil となる。
+func (e *Exception) Field(name string) (Object, bool) {
+	switch name {
+	case "message":
+		return &String{Value: e.Err.Message}, true
+	case "kind":
+		return &String{Value: e.Err.Kind}, true
+	case "stack":
+		frames := make([]Object, len(e.Err.Stack))
+		for i, f := range e.Err.Stack {
+			frames[i] = &String{Value: f.String()}
+		}
+		return &Array{Elements: frames}, true
+	case "value":
+		return e.Err.Value, true
+	default:
+		return nil, false
+	}
+}
 
 // Builtin 組み込み関数
 type Builtin struct {
@@ -199,19 +527,90 @@ type Array struct {
 func (ao *Array) Type() Type { return ArrayObj }
 
 // Inspect オブジェクトの値を返却する。
-func (ao *Array) Inspect() string {
-	var out bytes.Buffer
+func (ao *Array) Inspect() string { return inspect(ao, map[Object]bool{}) }
+
+// HashKey 要素のハッシュキーからハッシュキーを取得する。
+// キーとして使用できない要素(AsHashable を参照)と自身への参照は、要素の種別のみをハッシュ値に含める。
+func (ao *Array) HashKey() HashKey {
+	return ao.hashKey(map[*Array]bool{})
+}
+
+// hashKey visiting に含まれる配列への参照を辿らずにハッシュキーを取得する。
+func (ao *Array) hashKey(visiting map[*Array]bool) HashKey {
+	visiting[ao] = true
+	defer delete(visiting, ao)
+
+	h := fnv.New64a()
+	var buf [8]byte
 
-	elements := []string{}
 	for _, e := range ao.Elements {
-		elements = append(elements, e.Inspect())
+		var key HashKey
+		switch e := e.(type) {
+		case *Array:
+			if visiting[e] {
+				key = HashKey{Type: e.Type()}
+			} else {
+				key = e.hashKey(visiting)
+			}
+		case Hashable:
+			key = e.HashKey()
+		default:
+			key = HashKey{Type: e.Type()}
+		}
+
+		if _, ok := h.Write([]byte(key.Type)); ok != nil {
+			panic(ok.Error())
+		}
+		binary.LittleEndian.PutUint64(buf[:], key.Value)
+		if _, ok := h.Write(buf[:]); ok != nil {
+			panic(ok.Error())
+		}
 	}
 
-	out.WriteString("[")
-	out.WriteString(strings.Join(elements, ", "))
-	out.WriteString("]")
+	return HashKey{Type: ao.Type(), Value: h.Sum64()}
+}
 
-	return out.String()
+// KeyEquals other が同じ長さで、全ての要素が等しいキーの配列かを返却する。
+// キーとして使用できない要素は同一のオブジェクトの場合に限り等しいとする。
+func (ao *Array) KeyEquals(other Hashable) bool {
+	o, ok := other.(*Array)
+	return ok && arrayKeyEquals(ao, o, map[[2]*Array]bool{})
+}
+
+// arrayKeyEquals 配列 a と b が等しいキーかを返却する。
+// comparing は比較中の配列の組の集合で、比較中の組に再び到達した場合は等しいとみなす。
+func arrayKeyEquals(a, b *Array, comparing map[[2]*Array]bool) bool {
+	if len(a.Elements) != len(b.Elements) {
+		return false
+	}
+
+	pair := [2]*Array{a, b}
+	if comparing[pair] {
+		return true
+	}
+	comparing[pair] = true
+	defer delete(comparing, pair)
+
+	for i, e := range a.Elements {
+		other := b.Elements[i]
+		switch e := e.(type) {
+		case *Array:
+			o, ok := other.(*Array)
+			if !ok || !arrayKeyEquals(e, o, comparing) {
+				return false
+			}
+		case Hashable:
+			o, ok := other.(Hashable)
+			if !ok || !e.KeyEquals(o) {
+				return false
+			}
+		default:
+			if e != other {
+				return false
+			}
+		}
+	}
+	return true
 }
 
 // HashPair ハッシュペア
@@ -220,27 +619,245 @@ type HashPair struct {
 	Value Object
 }
 
-// Hash ハッシュ
+// Hash ハッシュ。キーと値の組をキーの挿入順に保持し、キーのハッシュ値で検索する。
+// ハッシュ値が衝突したキーは同じバケットに格納し、KeyEquals で区別する。
+// ゼロ値は空のハッシュとして使用できる。
 type Hash struct {
-	Pairs map[HashKey]HashPair
+	pairs []HashPair        // キーの挿入順に並べたキーと値の組
+	index map[HashKey][]int // キーのハッシュ値から、そのハッシュ値を持つ組の pairs での添字への対応
+}
+
+// NewHash 組を size 個まで再割り当てなしに格納できる空のハッシュを生成する。
+func NewHash(size int) *Hash {
+	return &Hash{
+		pairs: make([]HashPair, 0, size),
+		index: make(map[HashKey][]int, size),
+	}
 }
 
 // Type オブジェクトのタイプを返却する。
 func (h *Hash) Type() Type { return HashObj }
 
+// Len 組の数を返却する。
+func (h *Hash) Len() int { return len(h.pairs) }
+
+// Pairs キーと値の組を挿入順に返却する。返却したスライスと配列のキーを変更してはならない。
+func (h *Hash) Pairs() []HashPair { return h.pairs }
+
+// Keys キーを挿入順に返却する。
+// 配列のキーは複製を返却するため、返却した値を変更してもハッシュには影響しない。
+func (h *Hash) Keys() []Object {
+	keys := make([]Object, len(h.pairs))
+	for i, pair := range h.pairs {
+		keys[i] = freezeKey(pair.Key, map[*Array]*Array{})
+	}
+	return keys
+}
+
+// Get キーに対応する組を返却する。存在しない場合は false を返却する。
+func (h *Hash) Get(key Hashable) (HashPair, bool) {
+	i, ok := h.find(key.HashKey(), key)
+	if !ok {
+		return HashPair{}, false
+	}
+	return h.pairs[i], true
+}
+
+// Set キーに値を対応付ける。既に存在するキーの場合は、順序を変えずに値を置き換える。
+// 配列のキーは複製して格納するため、格納した後に元の配列を変更してもキーは変わらない。
+func (h *Hash) Set(key Hashable, value Object) {
+	hashed := key.HashKey()
+	if i, ok := h.find(hashed, key); ok {
+		h.pairs[i].Value = value
+		return
+	}
+
+	if h.index == nil {
+		h.index = make(map[HashKey][]int)
+	}
+	h.index[hashed] = append(h.index[hashed], len(h.pairs))
+	h.pairs = append(h.pairs, HashPair{Key: freezeKey(key, map[*Array]*Array{}), Value: value})
+}
+
+// find ハッシュ値が hashed のバケットから key と等しいキーの組を探し、pairs での添字を返却する。
+func (h *Hash) find(hashed HashKey, key Hashable) (int, bool) {
+	for _, i := range h.index[hashed] {
+		if key.KeyEquals(h.pairs[i].Key.(Hashable)) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// freezeKey ハッシュに格納するキーを返却する。配列は要素の配列も含めて複製する。
+// copies は複製済みの配列から複製への対応で、同じ配列への参照は同じ複製への参照とする。
+func freezeKey(key Object, copies map[*Array]*Array) Object {
+	arr, ok := key.(*Array)
+	if !ok {
+		return key
+	}
+	if c, ok := copies[arr]; ok {
+		return c
+	}
+
+	c := &Array{Elements: make([]Object, len(arr.Elements))}
+	copies[arr] = c
+	for i, e := range arr.Elements {
+		c.Elements[i] = freezeKey(e, copies)
+	}
+	return c
+}
+
+// Equal 2つのハッシュが同じ順序で等しいキーと値の組を持つかを返却する。
+// 値の配列とハッシュは要素を比較し、それ以外の値はキーとして等しいか(KeyEquals)、同一のオブジェクトかで比較する。
+func (h *Hash) Equal(other *Hash) bool {
+	return equal(h, other, map[[2]Object]bool{})
+}
+
+// equal a と b を比較する。comparing は比較中の配列とハッシュの組の集合で、
+// 比較中の組に再び到達した場合は等しいとみなす。
+func equal(a, b Object, comparing map[[2]Object]bool) bool {
+	if a == b {
+		return true
+	}
+
+	switch a := a.(type) {
+	case *Hash:
+		o, ok := b.(*Hash)
+		if !ok || a.Len() != o.Len() {
+			return false
+		}
+
+		pair := [2]Object{a, o}
+		if comparing[pair] {
+			return true
+		}
+		comparing[pair] = true
+		defer delete(comparing, pair)
+
+		for i, p := range a.pairs {
+			q := o.pairs[i]
+			if !equal(p.Key, q.Key, comparing) || !equal(p.Value, q.Value, comparing) {
+				return false
+			}
+		}
+		return true
+
+	case *Array:
+		o, ok := b.(*Array)
+		if !ok || len(a.Elements) != len(o.Elements) {
+			return false
+		}
+
+		pair := [2]Object{a, o}
+		if comparing[pair] {
+			return true
+		}
+		comparing[pair] = true
+		defer delete(comparing, pair)
+
+		for i, e := range a.Elements {
+			if !equal(e, o.Elements[i], comparing) {
+				return false
+			}
+		}
+		return true
+
+	case Hashable:
+		o, ok := b.(Hashable)
+		return ok && a.KeyEquals(o)
+
+	default:
+		return false
+	}
+}
+
 // Inspect オブジェクトの値を返却する。
-func (h *Hash) Inspect() string {
+func (h *Hash) Inspect() string { return inspect(h, map[Object]bool{}) }
+
+// inspect 配列とハッシュの値を返却する。visiting は出力中の配列とハッシュの集合で、
+// 自身を含む配列とハッシュの自身への参照は "[...]" または "{...}" と出力する。
+func inspect(obj Object, visiting map[Object]bool) string {
 	var out bytes.Buffer
 
-	pairs := []string{}
-	for _, pair := range h.Pairs {
-		pairs = append(pairs, fmt.Sprintf("%s: %s",
-			pair.Key.Inspect(), pair.Value.Inspect()))
+	switch obj := obj.(type) {
+	case *Array:
+		if visiting[obj] {
+			return "[...]"
+		}
+		visiting[obj] = true
+		defer delete(visiting, obj)
+
+		elements := []string{}
+		for _, e := range obj.Elements {
+			elements = append(elements, inspect(e, visiting))
+		}
+
+		out.WriteString("[")
+		out.WriteString(strings.Join(elements, ", "))
+		out.WriteString("]")
+
+	case *Hash:
+		if visiting[obj] {
+			return "{...}"
+		}
+		visiting[obj] = true
+		defer delete(visiting, obj)
+
+		pairs := []string{}
+		for _, pair := range obj.pairs {
+			pairs = append(pairs, fmt.Sprintf("%s: %s",
+				inspect(pair.Key, visiting), inspect(pair.Value, visiting)))
+		}
+
+		out.WriteString("{")
+		out.WriteString(strings.Join(pairs, ", "))
+		out.WriteString("}")
+
+	default:
+		return obj.Inspect()
 	}
 
-	out.WriteString("{")
-	out.WriteString(strings.Join(pairs, ", "))
-	out.WriteString("}")
-
 	return out.String()
 }
+
+// Module import で読み込んだモジュール。export された束縛を添字で参照できる。
+type Module struct {
+	Path    string            // モジュールのファイルパス
+	Exports map[string]Object // export された束縛
+}
+
+// Type オブジェクトのタイプを返却する。
+func (m *Module) Type() Type { return ModuleObj }
+
+// Inspect オブジェクトの値を返却する。
+func (m *Module) Inspect() string { return "module " + m.Path }
+
+// CompiledFunction コンパイル済み関数
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	NumLocals     int // ローカル変数の数
+	NumParameters int // 仮引数の数
+}
+
+// Type オブジェクトのタイプを返却する。
+func (cf *CompiledFunction) Type() Type { return CompiledFunctionObj }
+
+// Inspect オブジェクトの値を返却する。
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", cf)
+}
+
+// Closure コンパイル済み関数と自由変数の組
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []Object
+}
+
+// Type オブジェクトのタイプを返却する。
+func (c *Closure) Type() Type { return ClosureObj }
+
+// Inspect オブジェクトの値を返却する。
+func (c *Closure) Inspect() string {
+	return fmt.Sprintf("Closure[%p]", c)
+}
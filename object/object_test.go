@@ -1,6 +1,10 @@
 package object
 
-import "testing"
+import (
+	"math"
+	"math/big"
+	"testing"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -58,3 +62,220 @@ func TestIntegerHashKey(t *testing.T) {
 		t.Errorf("integers with twoerent content have same hash keys")
 	}
 }
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{1.5, "1.5"},
+		{2, "2.0"},
+		{-3, "-3.0"},
+		{1e21, "1e+21"},
+		{0.1, "0.1"},
+	}
+
+	for _, tt := range tests {
+		f := &Float{Value: tt.value}
+		if f.Inspect() != tt.expected {
+			t.Errorf("wrong inspect for %v. expected=%q, got=%q", tt.value, tt.expected, f.Inspect())
+		}
+	}
+}
+
+func TestFloatHashKey(t *testing.T) {
+	if (&Float{Value: 1.5}).HashKey() != (&Float{Value: 1.5}).HashKey() {
+		t.Errorf("floats with same content have different hash keys")
+	}
+	if (&Float{Value: 1.5}).HashKey() == (&Float{Value: 2.5}).HashKey() {
+		t.Errorf("floats with different content have same hash keys")
+	}
+	if (&Float{Value: 0}).HashKey() != (&Float{Value: math.Copysign(0, -1)}).HashKey() {
+		t.Errorf("0 and -0 have different hash keys")
+	}
+	nan := &Float{Value: math.NaN()}
+	if nan.HashKey() != (&Float{Value: -math.NaN()}).HashKey() || !nan.KeyEquals(nan) {
+		t.Errorf("NaN is not usable as a key")
+	}
+
+	// 値が整数の浮動小数点数は、数値として等しい整数と同じキーとなる。
+	integral := []struct {
+		f Float
+		n Hashable
+	}{
+		{Float{Value: 1}, &Integer{Value: 1}},
+		{Float{Value: -3}, &Integer{Value: -3}},
+		{Float{Value: math.Copysign(0, -1)}, &Integer{Value: 0}},
+		{Float{Value: 1e20}, &BigInt{Value: new(big.Int).Exp(big.NewInt(10), big.NewInt(20), nil)}},
+	}
+	for _, tt := range integral {
+		if tt.f.HashKey() != tt.n.HashKey() || !tt.f.KeyEquals(tt.n) || !tt.n.KeyEquals(&tt.f) {
+			t.Errorf("%s and %s are different keys", tt.f.Inspect(), tt.n.Inspect())
+		}
+	}
+	if (&Float{Value: 1.5}).KeyEquals(&Integer{Value: 1}) || (&Integer{Value: 1}).KeyEquals(&Float{Value: 1.5}) {
+		t.Errorf("1.5 and 1 are same keys")
+	}
+	if (&Float{Value: math.Inf(1)}).KeyEquals(&Integer{Value: math.MaxInt64}) {
+		t.Errorf("Inf and MaxInt64 are same keys")
+	}
+}
+
+func TestArrayHashKey(t *testing.T) {
+	arr := func(elements ...Object) *Array { return &Array{Elements: elements} }
+
+	a1 := arr(&Integer{Value: 1}, &String{Value: "a"})
+	a2 := arr(&Integer{Value: 1}, &String{Value: "a"})
+	b := arr(&String{Value: "a"}, &Integer{Value: 1})
+
+	if a1.HashKey() != a2.HashKey() || !a1.KeyEquals(a2) {
+		t.Errorf("arrays with same content are different keys")
+	}
+	if a1.HashKey() == b.HashKey() || a1.KeyEquals(b) {
+		t.Errorf("arrays with different content are same keys")
+	}
+	if arr().KeyEquals(arr(&Integer{Value: 1})) {
+		t.Errorf("arrays with different length are same keys")
+	}
+
+	if _, ok := AsHashable(arr(arr(True, &Float{Value: 1.5}))); !ok {
+		t.Errorf("nested array of hashables is not hashable")
+	}
+	if _, ok := AsHashable(arr(&Integer{Value: 1}, arr(&Hash{}))); ok {
+		t.Errorf("array containing a hash is hashable")
+	}
+	if _, ok := AsHashable(NullValue); ok {
+		t.Errorf("null is hashable")
+	}
+}
+
+// collidingKey ハッシュ値が全て衝突するキー
+type collidingKey struct {
+	name string
+}
+
+func (k *collidingKey) Type() Type      { return "COLLIDING" }
+func (k *collidingKey) Inspect() string { return k.name }
+func (k *collidingKey) HashKey() HashKey {
+	return HashKey{Type: k.Type(), Value: 0}
+}
+func (k *collidingKey) KeyEquals(other Hashable) bool {
+	o, ok := other.(*collidingKey)
+	return ok && o.name == k.name
+}
+
+func TestHashCollisions(t *testing.T) {
+	h := &Hash{}
+	h.Set(&collidingKey{"a"}, &Integer{Value: 1})
+	h.Set(&collidingKey{"b"}, &Integer{Value: 2})
+	h.Set(&collidingKey{"a"}, &Integer{Value: 3})
+
+	if h.Len() != 2 {
+		t.Fatalf("wrong number of pairs. got=%d", h.Len())
+	}
+	if h.Inspect() != "{a: 3, b: 2}" {
+		t.Errorf("wrong Inspect. got=%q", h.Inspect())
+	}
+
+	for name, expected := range map[string]int64{"a": 3, "b": 2} {
+		pair, ok := h.Get(&collidingKey{name})
+		if !ok {
+			t.Errorf("no pair for key %s", name)
+			continue
+		}
+		if pair.Value.(*Integer).Value != expected {
+			t.Errorf("wrong value for key %s. got=%s", name, pair.Value.Inspect())
+		}
+	}
+
+	if _, ok := h.Get(&collidingKey{"c"}); ok {
+		t.Errorf("found pair for missing key")
+	}
+}
+
+func TestHashArrayKeyIsCopied(t *testing.T) {
+	key := &Array{Elements: []Object{&Integer{Value: 1}}}
+
+	h := &Hash{}
+	h.Set(key, True)
+	key.Elements[0] = &Integer{Value: 2}
+
+	if _, ok := h.Get(&Array{Elements: []Object{&Integer{Value: 1}}}); !ok {
+		t.Errorf("key changed after mutating the original array")
+	}
+	if _, ok := h.Get(key); ok {
+		t.Errorf("found pair for mutated array")
+	}
+
+	keys := h.Keys()
+	keys[0].(*Array).Elements[0] = &Integer{Value: 3}
+
+	if _, ok := h.Get(&Array{Elements: []Object{&Integer{Value: 1}}}); !ok {
+		t.Errorf("key changed after mutating the array returned by Keys")
+	}
+}
+
+func TestSelfContainingArray(t *testing.T) {
+	a := &Array{Elements: []Object{&Integer{Value: 1}}}
+	a.Elements = append(a.Elements, a)
+
+	if _, ok := AsHashable(a); ok {
+		t.Errorf("self-containing array is hashable")
+	}
+	if _, ok := AsHashable(&Array{Elements: []Object{a}}); ok {
+		t.Errorf("array containing a self-containing array is hashable")
+	}
+
+	// 同じ配列を複数回含むだけの配列はキーとして使用できる。
+	shared := &Array{Elements: []Object{&Integer{Value: 1}}}
+	if _, ok := AsHashable(&Array{Elements: []Object{shared, shared}}); !ok {
+		t.Errorf("array sharing an element array is not hashable")
+	}
+
+	// 自身を含む配列に対して直接呼び出しても終了する。
+	b := &Array{Elements: []Object{&Integer{Value: 1}}}
+	b.Elements = append(b.Elements, b)
+	if a.HashKey() != b.HashKey() || !a.KeyEquals(b) {
+		t.Errorf("structurally equal self-containing arrays are different keys")
+	}
+
+	if a.Inspect() != "[1, [...]]" {
+		t.Errorf("wrong Inspect. got=%q", a.Inspect())
+	}
+	h := &Hash{}
+	h.Set(&String{Value: "self"}, h)
+	if h.Inspect() != "{self: {...}}" {
+		t.Errorf("wrong Inspect. got=%q", h.Inspect())
+	}
+}
+
+func TestHashEqual(t *testing.T) {
+	str := func(s string) *String { return &String{Value: s} }
+
+	a := &Hash{}
+	a.Set(str("x"), &Integer{Value: 1})
+	a.Set(str("y"), &Array{Elements: []Object{&Float{Value: 2}}})
+
+	b := &Hash{}
+	b.Set(str("x"), &Integer{Value: 1})
+	b.Set(str("y"), &Array{Elements: []Object{&Integer{Value: 2}}})
+
+	if !a.Equal(b) {
+		t.Errorf("%s != %s", a.Inspect(), b.Inspect())
+	}
+
+	c := &Hash{}
+	c.Set(str("y"), &Array{Elements: []Object{&Integer{Value: 2}}})
+	c.Set(str("x"), &Integer{Value: 1})
+	if a.Equal(c) {
+		t.Errorf("hashes with different order are equal")
+	}
+
+	// 自身を含むハッシュの比較も終了する。
+	d, e := &Hash{}, &Hash{}
+	d.Set(str("self"), d)
+	e.Set(str("self"), e)
+	if !d.Equal(e) {
+		t.Errorf("structurally equal self-containing hashes are not equal")
+	}
+}
@@ -2,36 +2,59 @@ package parser
 
 import (
 	"fmt"
+	"math/big"
 	"monkey/ast"
 	"monkey/lexer"
 	"monkey/token"
 	"strconv"
+	"strings"
 )
 
 /// 演算子の優先順位
 const (
 	_ int = iota
 	lowest
+	assign      // = or +=
+	coalesce    // ??
+	logicalOr   // ||
+	logicalAnd  // &&
 	equals      // ==
 	lessgreater // > or <
-	sum         // +
-	product     // *
+	sum         // + or |
+	product     // * or <<
 	prefix      // -X or !X
 	call        // myFunction(X)
 	index       // array[index]
 )
 
 var precedences = map[token.Type]int{
-	token.Eq:       equals,
-	token.NotEq:    equals,
-	token.Lt:       lessgreater,
-	token.Gt:       lessgreater,
-	token.Plus:     sum,
-	token.Minus:    sum,
-	token.Slash:    product,
-	token.Asterisk: product,
-	token.Lparen:   call,
-	token.Lbracket: index,
+	token.Assign:           assign,
+	token.PlusAssign:       assign,
+	token.MinusAssign:      assign,
+	token.AsteriskAssign:   assign,
+	token.SlashAssign:      assign,
+	token.NullCoalesce:     coalesce,
+	token.Or:               logicalOr,
+	token.And:              logicalAnd,
+	token.Eq:               equals,
+	token.NotEq:            equals,
+	token.Lt:               lessgreater,
+	token.Gt:               lessgreater,
+	token.LtEq:             lessgreater,
+	token.GtEq:             lessgreater,
+	token.Plus:             sum,
+	token.Minus:            sum,
+	token.Pipe:             sum,
+	token.Caret:            sum,
+	token.Slash:            product,
+	token.Asterisk:         product,
+	token.Percent:          product,
+	token.Ampersand:        product,
+	token.ShiftLeft:        product,
+	token.ShiftRight:       product,
+	token.Lparen:           call,
+	token.Lbracket:         index,
+	token.OptionalLbracket: index,
 }
 
 type (
@@ -49,6 +72,9 @@ type Parser struct {
 
 	prefixParseFn map[token.Type]prefixParseFn
 	infixParseFn  map[token.Type]infixParseFn
+
+	loopDepth  int // 解析中のループの入れ子の深さ。関数リテラルに入ると0に戻る。
+	blockDepth int // 解析中のブロックの入れ子の深さ。0 の場合は最上位の文を解析している。
 }
 
 // New 構文解析器を生成する。
@@ -61,16 +87,21 @@ func New(l *lexer.Lexer) *Parser {
 	p.prefixParseFn = make(map[token.Type]prefixParseFn)
 	p.registerPrefix(token.Ident, p.parseIdentifier)
 	p.registerPrefix(token.Int, p.parseIntegerLiteral)
+	p.registerPrefix(token.Float, p.parseFloatLiteral)
 	p.registerPrefix(token.String, p.parseStringLiteral)
 	p.registerPrefix(token.Bang, p.parsePrefixExpression)
 	p.registerPrefix(token.Minus, p.parsePrefixExpression)
 	p.registerPrefix(token.True, p.parseBoolean)
 	p.registerPrefix(token.False, p.parseBoolean)
+	p.registerPrefix(token.Null, p.parseNullLiteral)
 	p.registerPrefix(token.Lparen, p.parseGroupedExpression)
 	p.registerPrefix(token.If, p.parseIfExpression)
+	p.registerPrefix(token.Try, p.parseTryExpression)
+	p.registerPrefix(token.Import, p.parseImportExpression)
 	p.registerPrefix(token.Function, p.parseFunctionLiteral)
 	p.registerPrefix(token.Lbracket, p.parseArrayLiteral)
 	p.registerPrefix(token.Lbrace, p.parseHashLiteral)
+	p.registerPrefix(token.Illegal, p.parseIllegal)
 
 	p.infixParseFn = make(map[token.Type]infixParseFn)
 	p.registerInfix(token.Plus, p.parseInfixExpression)
@@ -81,8 +112,25 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.NotEq, p.parseInfixExpression)
 	p.registerInfix(token.Lt, p.parseInfixExpression)
 	p.registerInfix(token.Gt, p.parseInfixExpression)
+	p.registerInfix(token.LtEq, p.parseInfixExpression)
+	p.registerInfix(token.GtEq, p.parseInfixExpression)
+	p.registerInfix(token.Percent, p.parseInfixExpression)
+	p.registerInfix(token.And, p.parseInfixExpression)
+	p.registerInfix(token.Or, p.parseInfixExpression)
+	p.registerInfix(token.NullCoalesce, p.parseInfixExpression)
+	p.registerInfix(token.Ampersand, p.parseInfixExpression)
+	p.registerInfix(token.Pipe, p.parseInfixExpression)
+	p.registerInfix(token.Caret, p.parseInfixExpression)
+	p.registerInfix(token.ShiftLeft, p.parseInfixExpression)
+	p.registerInfix(token.ShiftRight, p.parseInfixExpression)
 	p.registerInfix(token.Lparen, p.parseCallExpression)
 	p.registerInfix(token.Lbracket, p.parseIndexExpression)
+	p.registerInfix(token.OptionalLbracket, p.parseIndexExpression)
+	p.registerInfix(token.Assign, p.parseAssignExpression)
+	p.registerInfix(token.PlusAssign, p.parseAssignExpression)
+	p.registerInfix(token.MinusAssign, p.parseAssignExpression)
+	p.registerInfix(token.AsteriskAssign, p.parseAssignExpression)
+	p.registerInfix(token.SlashAssign, p.parseAssignExpression)
 
 	// 2つのトークンを読み込む。curTokenとpeekTokenの両方がセットされる。
 	p.nextToken()
@@ -125,14 +173,18 @@ func (p *Parser) Errors() []string {
 	return p.errors
 }
 
-func (p *Parser) peekError(t token.Type) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
+// errorf 位置情報を付与してエラーを追加する。
+func (p *Parser) errorf(pos token.Position, format string, a ...interface{}) {
+	msg := pos.String() + ": " + fmt.Sprintf(format, a...)
 	p.errors = append(p.errors, msg)
 }
 
+func (p *Parser) peekError(t token.Type) {
+	p.errorf(p.peekToken.Pos, "expected next token to be %s, got %s instead", t, p.peekToken.Type)
+}
+
 func (p *Parser) noPrefixParseFnError(t token.Type) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.errorf(p.curToken.Pos, "no prefix parse function for %s found", t)
 }
 
 // ParseProgram プログラムの構文解析を行う。
@@ -156,6 +208,21 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseLetStatement()
 	case token.Return:
 		return p.parseReturnStatement()
+	case token.While:
+		return p.parseWhileStatement()
+	case token.For:
+		return p.parseForStatement()
+	case token.Break, token.Continue:
+		return p.parseLoopControlStatement()
+	case token.Throw:
+		return p.parseThrowStatement()
+	case token.Export:
+		return p.parseExportStatement()
+	case token.Function:
+		if p.peekTokenIs(token.Ident) {
+			return p.parseFunctionStatement()
+		}
+		return p.parseExpressionStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -178,6 +245,10 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 
 	stmt.Value = p.parseExpression(lowest)
 
+	if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+		fl.Name = stmt.Name.Value
+	}
+
 	if p.peekTokenIs(token.Semicolon) {
 		p.nextToken()
 	}
@@ -199,6 +270,146 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
+func (p *Parser) parseThrowStatement() ast.Statement {
+	stmt := &ast.ThrowStatement{Token: p.curToken}
+
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(lowest)
+	if stmt.Value == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.Semicolon) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseExportStatement export文を解析する。export できるのは最上位の let 文と関数宣言のみとする。
+func (p *Parser) parseExportStatement() ast.Statement {
+	stmt := &ast.ExportStatement{Token: p.curToken}
+
+	if p.blockDepth > 0 {
+		p.errorf(stmt.Token.Pos, "export outside top level")
+		return nil
+	}
+
+	p.nextToken()
+
+	switch {
+	case p.curTokenIs(token.Let):
+		let := p.parseLetStatement()
+		if let == nil {
+			return nil
+		}
+		stmt.Statement = let
+	case p.curTokenIs(token.Function) && p.peekTokenIs(token.Ident):
+		fn := p.parseFunctionStatement()
+		if fn == nil {
+			return nil
+		}
+		stmt.Statement = fn
+	default:
+		p.errorf(p.curToken.Pos, "expected let or function declaration after export, got %s", p.curToken.Type)
+		return nil
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseWhileStatement() ast.Statement {
+	stmt := &ast.WhileStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.Lparen) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Condition = p.parseExpression(lowest)
+
+	if !p.expectPeek(token.Rparen) {
+		return nil
+	}
+
+	if !p.expectPeek(token.Lbrace) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+
+	if p.peekTokenIs(token.Semicolon) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseForStatement() ast.Statement {
+	stmt := &ast.ForStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.Lparen) {
+		return nil
+	}
+
+	if !p.expectPeek(token.Ident) {
+		return nil
+	}
+
+	stmt.Variable = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.In) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Iterable = p.parseExpression(lowest)
+
+	if !p.expectPeek(token.Rparen) {
+		return nil
+	}
+
+	if !p.expectPeek(token.Lbrace) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+
+	if p.peekTokenIs(token.Semicolon) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseLoopBody ループ本体のブロックを解析する。本体の中では break と continue を使用できる。
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	p.loopDepth++
+	defer func() { p.loopDepth-- }()
+
+	return p.parseBlockStatement()
+}
+
+// parseLoopControlStatement break または continue を解析する。ループの外で使用された場合はエラーとする。
+func (p *Parser) parseLoopControlStatement() ast.Statement {
+	tok := p.curToken
+
+	if p.loopDepth == 0 {
+		p.errorf(tok.Pos, "%s outside loop", tok.Literal)
+		return nil
+	}
+
+	if p.peekTokenIs(token.Semicolon) {
+		p.nextToken()
+	}
+
+	if tok.Type == token.Break {
+		return &ast.BreakStatement{Token: tok}
+	}
+	return &ast.ContinueStatement{Token: tok}
+}
+
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 
@@ -253,13 +464,48 @@ func (p *Parser) parseIdentifier() ast.Expression {
 	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 }
 
+// parseIllegal 字句解析器が不正と判定したトークンをエラーとして報告する。
+func (p *Parser) parseIllegal() ast.Expression {
+	literal := p.curToken.Literal
+
+	switch {
+	case strings.HasPrefix(literal, "/*"):
+		p.errorf(p.curToken.Pos, "unterminated block comment")
+	case strings.HasPrefix(literal, `"`):
+		if _, err := lexer.Unquote(literal); err != nil {
+			p.errorf(p.curToken.Pos, "%s", err)
+		}
+	default:
+		p.errorf(p.curToken.Pos, "illegal token %q", literal)
+	}
+	return nil
+}
+
 func (p *Parser) parseIntegerLiteral() ast.Expression {
 	lit := &ast.IntegerLiteral{Token: p.curToken}
 
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		bigValue, ok := new(big.Int).SetString(p.curToken.Literal, 0)
+		if !ok {
+			p.errorf(p.curToken.Pos, "could not parse %q as integer", p.curToken.Literal)
+			return nil
+		}
+		lit.BigValue = bigValue
+		return lit
+	}
+
+	lit.Value = value
+
+	return lit
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.curToken}
+
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		p.errorf(p.curToken.Pos, "could not parse %q as float", p.curToken.Literal)
 		return nil
 	}
 
@@ -299,10 +545,40 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	return expression
 }
 
+// parseAssignExpression 代入式を解析する。代入は右結合であり、`a = b = 1` は `a = (b = 1)` となる。
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	expression := &ast.AssignExpression{
+		Token:    p.curToken,
+		Operator: p.curToken.Literal,
+		Target:   target,
+	}
+
+	switch target := target.(type) {
+	case *ast.Identifier:
+	case *ast.IndexExpression:
+		if target.Optional {
+			p.errorf(p.curToken.Pos, "invalid assignment target: %s", target.String())
+			return nil
+		}
+	default:
+		p.errorf(p.curToken.Pos, "invalid assignment target: %s", target.String())
+		return nil
+	}
+
+	p.nextToken()
+	expression.Value = p.parseExpression(assign - 1)
+
+	return expression
+}
+
 func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.True)}
 }
 
+func (p *Parser) parseNullLiteral() ast.Expression {
+	return &ast.NullLiteral{Token: p.curToken}
+}
+
 func (p *Parser) parseGroupedExpression() ast.Expression {
 	p.nextToken()
 
@@ -348,10 +624,78 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expression
 }
 
+// parseImportExpression import式を解析する。モジュールのパスは文字列リテラルで指定する。
+func (p *Parser) parseImportExpression() ast.Expression {
+	expression := &ast.ImportExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.String) {
+		return nil
+	}
+
+	expression.Path = p.curToken.Literal
+
+	return expression
+}
+
+// parseTryExpression try式を解析する。catch 節と finally 節の少なくとも一方が必要となる。
+func (p *Parser) parseTryExpression() ast.Expression {
+	expression := &ast.TryExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.Lbrace) {
+		return nil
+	}
+
+	expression.Block = p.parseBlockStatement()
+
+	if p.peekTokenIs(token.Catch) {
+		p.nextToken()
+
+		if p.peekTokenIs(token.Lparen) {
+			p.nextToken()
+
+			if !p.expectPeek(token.Ident) {
+				return nil
+			}
+
+			expression.CatchParam = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+			if !p.expectPeek(token.Rparen) {
+				return nil
+			}
+		}
+
+		if !p.expectPeek(token.Lbrace) {
+			return nil
+		}
+
+		expression.Catch = p.parseBlockStatement()
+	}
+
+	if p.peekTokenIs(token.Finally) {
+		p.nextToken()
+
+		if !p.expectPeek(token.Lbrace) {
+			return nil
+		}
+
+		expression.Finally = p.parseBlockStatement()
+	}
+
+	if expression.Catch == nil && expression.Finally == nil {
+		p.errorf(expression.Token.Pos, "try without catch or finally")
+		return nil
+	}
+
+	return expression
+}
+
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
 
+	p.blockDepth++
+	defer func() { p.blockDepth-- }()
+
 	p.nextToken()
 
 	for !p.curTokenIs(token.Rbrace) && !p.curTokenIs(token.EOF) {
@@ -368,46 +712,156 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 func (p *Parser) parseFunctionLiteral() ast.Expression {
 	lit := &ast.FunctionLiteral{Token: p.curToken}
 
-	if !p.expectPeek(token.Lparen) {
+	if !p.parseFunction(lit) {
 		return nil
 	}
 
-	lit.Parameters = p.parseFunctionParameters()
+	return lit
+}
 
-	if !p.expectPeek(token.Lbrace) {
+// parseFunctionStatement 関数宣言(fn name(params) { body })を解析する。
+func (p *Parser) parseFunctionStatement() ast.Statement {
+	stmt := &ast.FunctionStatement{Token: p.curToken}
+	lit := &ast.FunctionLiteral{Token: p.curToken}
+
+	if !p.expectPeek(token.Ident) {
+		return nil
+	}
+
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	lit.Name = stmt.Name.Value
+
+	if !p.parseFunction(lit) {
 		return nil
 	}
+	stmt.Function = lit
+
+	if p.peekTokenIs(token.Semicolon) {
+		p.nextToken()
+	}
 
+	return stmt
+}
+
+// parseFunction 関数の仮引数と本体を解析する。
+func (p *Parser) parseFunction(lit *ast.FunctionLiteral) bool {
+	if !p.expectPeek(token.Lparen) {
+		return false
+	}
+
+	if !p.parseFunctionParameters(lit) {
+		return false
+	}
+
+	if !p.expectPeek(token.Lbrace) {
+		return false
+	}
+
+	// 関数の本体は外側のループとは独立しているため、break と continue は使用できない。
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
 	lit.Body = p.parseBlockStatement()
+	p.loopDepth = loopDepth
 
-	return lit
+	markTailCalls(lit.Body, true)
+
+	return true
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	identifiers := []*ast.Identifier{}
+// markTailCalls 関数本体のうち末尾位置にある呼び出しに印を付ける。
+// last はブロックの最後の文が関数の値となるかを表す。
+// return の値はループの中でも末尾位置となるが、try の中は catch と finally が残るため対象外とする。
+// 入れ子の関数リテラルはそれ自身の解析時に処理されるため、ここでは辿らない。
+func markTailCalls(block *ast.BlockStatement, last bool) {
+	if block == nil {
+		return
+	}
+
+	for i, stmt := range block.Statements {
+		isLast := last && i == len(block.Statements)-1
+
+		switch stmt := stmt.(type) {
+		case *ast.ReturnStatement:
+			markTailExpression(stmt.ReturnValue, true)
+		case *ast.ExpressionStatement:
+			markTailExpression(stmt.Expression, isLast)
+		case *ast.WhileStatement:
+			markTailCalls(stmt.Body, false)
+		case *ast.ForStatement:
+			markTailCalls(stmt.Body, false)
+		}
+	}
+}
+
+// markTailExpression 式が末尾位置にある場合は呼び出しに印を付け、if式の各分岐を辿る。
+func markTailExpression(exp ast.Expression, tail bool) {
+	switch exp := exp.(type) {
+	case *ast.CallExpression:
+		exp.Tail = tail
+	case *ast.IfExpression:
+		markTailCalls(exp.Consequence, tail)
+		markTailCalls(exp.Alternative, tail)
+	}
+}
+
+// parseFunctionParameters 仮引数の並びを解析する。
+// 仮引数には既定値(b = 2)を指定でき、最後の仮引数は残余引数(...rest)とすることができる。
+// 既定値を持つ仮引数の後に、既定値を持たない仮引数を置くことはできない。
+func (p *Parser) parseFunctionParameters(lit *ast.FunctionLiteral) bool {
+	lit.Parameters = []*ast.Identifier{}
 
 	if p.peekTokenIs(token.Rparen) {
 		p.nextToken()
-		return identifiers
+		return true
 	}
 
-	p.nextToken()
+	defaults := []ast.Expression{}
+	hasDefault := false
 
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+	for {
+		if lit.Rest != nil {
+			p.errorf(p.peekToken.Pos, "rest parameter must be last")
+			return false
+		}
 
-	for p.peekTokenIs(token.Comma) {
-		p.nextToken()
+		if p.peekTokenIs(token.Ellipsis) {
+			p.nextToken()
+			if !p.expectPeek(token.Ident) {
+				return false
+			}
+			lit.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		} else {
+			if !p.expectPeek(token.Ident) {
+				return false
+			}
+			ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+			var def ast.Expression
+			if p.peekTokenIs(token.Assign) {
+				p.nextToken()
+				p.nextToken()
+				def = p.parseExpression(lowest)
+				hasDefault = true
+			} else if hasDefault {
+				p.errorf(ident.Token.Pos, "missing default value for parameter: %s", ident.Value)
+				return false
+			}
+
+			lit.Parameters = append(lit.Parameters, ident)
+			defaults = append(defaults, def)
+		}
+
+		if !p.peekTokenIs(token.Comma) {
+			break
+		}
 		p.nextToken()
-		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
 	}
 
-	if !p.expectPeek(token.Rparen) {
-		return nil
+	if hasDefault {
+		lit.Defaults = defaults
 	}
 
-	return identifiers
+	return p.expectPeek(token.Rparen)
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
@@ -449,7 +903,11 @@ func (p *Parser) parseArrayLiteral() ast.Expression {
 }
 
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
-	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+	exp := &ast.IndexExpression{
+		Token:    p.curToken,
+		Left:     left,
+		Optional: p.curTokenIs(token.OptionalLbracket),
+	}
 
 	p.nextToken()
 	exp.Index = p.parseExpression(lowest)
@@ -477,6 +935,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		value := p.parseExpression(lowest)
 
 		hash.Pairs[key] = value
+		hash.Keys = append(hash.Keys, key)
 
 		if !p.peekTokenIs(token.Rbrace) && !p.expectPeek(token.Comma) {
 			return nil
@@ -130,6 +130,61 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestBigIntegerLiteralExpression(t *testing.T) {
+	input := "123456789012345678901234567890;"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.IntegerLiteral. got=%T", stmt.Expression)
+	}
+	if literal.BigValue == nil {
+		t.Fatalf("literal.BigValue is nil")
+	}
+	if literal.BigValue.String() != "123456789012345678901234567890" {
+		t.Errorf("literal.BigValue not %s. got=%s", "123456789012345678901234567890", literal.BigValue)
+	}
+}
+
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14;", 3.14},
+		{"1e3;", 1000},
+		{"2.5e-1;", 0.25},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program has not enough statements. got=%d", len(program.Statements))
+		}
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+		}
+
+		literal, ok := stmt.Expression.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %g. got=%g", tt.expected, literal.Value)
+		}
+	}
+}
+
 func TestParsingPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
 		input    string
@@ -221,6 +276,53 @@ func TestParsingInfixExpressions(t *testing.T) {
 	}
 }
 
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedTarget   string
+		expectedOperator string
+		expectedValue    string
+	}{
+		{"x = 5;", "x", "=", "5"},
+		{"x += y;", "x", "+=", "y"},
+		{"x -= 1 + 2;", "x", "-=", "(1 + 2)"},
+		{"x *= 2;", "x", "*=", "2"},
+		{"x /= 2;", "x", "/=", "2"},
+		{`h["k"] = true;`, `(h[k])`, "=", "true"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statements. got=%d", len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+		}
+
+		exp, ok := stmt.Expression.(*ast.AssignExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.AssignExpression. got=%T", stmt.Expression)
+		}
+
+		if exp.Target.String() != tt.expectedTarget {
+			t.Errorf("exp.Target is not %q. got=%q", tt.expectedTarget, exp.Target.String())
+		}
+		if exp.Operator != tt.expectedOperator {
+			t.Errorf("exp.Operator is not %q. got=%q", tt.expectedOperator, exp.Operator)
+		}
+		if exp.Value.String() != tt.expectedValue {
+			t.Errorf("exp.Value is not %q. got=%q", tt.expectedValue, exp.Value.String())
+		}
+	}
+}
+
 func TestOperatorPrecedenceParsing(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -230,6 +332,14 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"-a * b",
 			"((-a) * b)",
 		},
+		{
+			"fn add(x, y) { x + y }",
+			"fn add(x, y) (x + y)",
+		},
+		{
+			"fn f(a = 1, ...r) { r }",
+			"fn f(a = 1, ...r) r",
+		},
 		{
 			"!-a",
 			"(!(-a))",
@@ -334,6 +444,50 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a * b[2], b[1], 2 * [1, 2][1])",
 			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
 		},
+		{
+			"a = b = c + 1",
+			"(a = (b = (c + 1)))",
+		},
+		{
+			"a <= b == c >= d",
+			"((a <= b) == (c >= d))",
+		},
+		{
+			"a || b && c == d",
+			"(a || (b && (c == d)))",
+		},
+		{
+			"a && b || c && d",
+			"((a && b) || (c && d))",
+		},
+		{
+			"a + b % c - d",
+			"((a + (b % c)) - d)",
+		},
+		{
+			"a | b & c ^ d",
+			"((a | (b & c)) ^ d)",
+		},
+		{
+			"1 << 2 + 3 >> 1 < 4",
+			"(((1 << 2) + (3 >> 1)) < 4)",
+		},
+		{
+			"a ?? b || c",
+			"(a ?? (b || c))",
+		},
+		{
+			"x = a ?? b ?? null",
+			"(x = ((a ?? b) ?? null))",
+		},
+		{
+			`h?["a"]?["b"][0]`,
+			"(((h?[a])?[b])[0])",
+		},
+		{
+			"a[1] += b * 2 == c",
+			"((a[1]) += ((b * 2) == c))",
+		},
 	}
 
 	for _, tt := range tests {
@@ -427,6 +581,130 @@ func TestIfExpression(t *testing.T) {
 	}
 }
 
+func TestWhileStatement(t *testing.T) {
+	input := `while (x < y) { x += 1; continue; }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Body does not contain %d statements. got=%d\n", 1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.WhileStatement. got=%T", program.Statements[0])
+	}
+
+	if !testInfixExpression(t, stmt.Condition, "x", "<", "y") {
+		return
+	}
+
+	if len(stmt.Body.Statements) != 2 {
+		t.Fatalf("body is not 2 statements. got=%d\n", len(stmt.Body.Statements))
+	}
+
+	if _, ok := stmt.Body.Statements[1].(*ast.ContinueStatement); !ok {
+		t.Errorf("Statements[1] is not ast.ContinueStatement. got=%T", stmt.Body.Statements[1])
+	}
+}
+
+func TestForStatement(t *testing.T) {
+	input := `for (x in [1, 2]) { if (x > 1) { break; } }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Body does not contain %d statements. got=%d\n", 1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ForStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ForStatement. got=%T", program.Statements[0])
+	}
+
+	if stmt.Variable.Value != "x" {
+		t.Errorf("stmt.Variable is not %q. got=%q", "x", stmt.Variable.Value)
+	}
+
+	if stmt.Iterable.String() != "[1, 2]" {
+		t.Errorf("stmt.Iterable is not %q. got=%q", "[1, 2]", stmt.Iterable.String())
+	}
+
+	if stmt.String() != "for (x in [1, 2]) if(x > 1) break;" {
+		t.Errorf("stmt.String() wrong. got=%q", stmt.String())
+	}
+}
+
+func TestLoopStatementTrailingSemicolon(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let i = 0; while (i < 3) { i += 1 }; i", []string{"let i = 0;", "while(i < 3) (i += 1)", "i"}},
+		{"for (x in xs) { x };\ny", []string{"for (x in xs) x", "y"}},
+		{"while (i < 1) { break; };", []string{"while(i < 1) break;"}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != len(tt.expected) {
+			t.Fatalf("%q: wrong number of statements. expected=%d, got=%d", tt.input, len(tt.expected), len(program.Statements))
+		}
+
+		for i, stmt := range program.Statements {
+			if stmt.String() != tt.expected[i] {
+				t.Errorf("%q: statement %d wrong. expected=%q, got=%q", tt.input, i, tt.expected[i], stmt.String())
+			}
+		}
+	}
+}
+
+func TestTryExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`try { f() } catch (e) { e["message"] } finally { g() }`, "try f() catch (e) (e[message]) finally g()"},
+		{`try { throw "x"; } catch { 1 }`, "try throw x; catch 1"},
+		{`try { f() } finally { g() }`, "try f() finally g()"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Body does not contain %d statements. got=%d\n", 1, len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+		}
+
+		exp, ok := stmt.Expression.(*ast.TryExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.TryExpression. got=%T", stmt.Expression)
+		}
+
+		if exp.String() != tt.expected {
+			t.Errorf("exp.String() wrong. expected=%q, got=%q", tt.expected, exp.String())
+		}
+	}
+}
+
 func TestIfElseExpression(t *testing.T) {
 	input := `if (x < y) { x } else { y }`
 
@@ -551,6 +829,204 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestFunctionDefaultAndRestParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(a, b = 2) { a + b }", "fn(a, b = 2) (a + b)"},
+		{"fn(a = 1, b = a * 2) { b }", "fn(a = 1, b = (a * 2)) b"},
+		{"fn(a, ...rest) { rest }", "fn(a, ...rest) rest"},
+		{"fn(a, b = 2, ...rest) { rest }", "fn(a, b = 2, ...rest) rest"},
+		{"fn(...rest) { rest }", "fn(...rest) rest"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function, ok := stmt.Expression.(*ast.FunctionLiteral)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.FunctionLiteral. got=%T", stmt.Expression)
+		}
+
+		if function.String() != tt.expected {
+			t.Errorf("function.String() wrong. expected=%q, got=%q", tt.expected, function.String())
+		}
+	}
+}
+
+func TestFunctionStatement(t *testing.T) {
+	input := `fn add(x, y = 1) { x + y }; fn(x) { x }(1)`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Body does not contain %d statements. got=%d\n", 2, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.FunctionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.FunctionStatement. got=%T", program.Statements[0])
+	}
+
+	if stmt.Name.Value != "add" || stmt.Function.Name != "add" {
+		t.Errorf("function name wrong. want 'add', got=%q, %q", stmt.Name.Value, stmt.Function.Name)
+	}
+
+	if stmt.String() != "fn add(x, y = 1) (x + y)" {
+		t.Errorf("stmt.String() wrong. got=%q", stmt.String())
+	}
+
+	if _, ok := program.Statements[1].(*ast.ExpressionStatement); !ok {
+		t.Errorf("program.Statements[1] is not ast.ExpressionStatement. got=%T", program.Statements[1])
+	}
+}
+
+func TestImportAndExport(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`let m = import "lib/math";`, []string{`let m = import "lib/math";`}},
+		{`import "a"["x"]`, []string{`(import "a"[x])`}},
+		{"export let x = 1;", []string{"export let x = 1;"}},
+		{"export fn f(a) { a } f(1)", []string{"export fn f(a) a", "f(1)"}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != len(tt.expected) {
+			t.Fatalf("%q: wrong number of statements. expected=%d, got=%d", tt.input, len(tt.expected), len(program.Statements))
+		}
+
+		for i, stmt := range program.Statements {
+			if stmt.String() != tt.expected[i] {
+				t.Errorf("statement %d wrong. expected=%q, got=%q", i, tt.expected[i], stmt.String())
+			}
+		}
+	}
+}
+
+func TestFunctionLiteralWithName(t *testing.T) {
+	input := `let myFunction = fn() { };`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Body does not contain %d statements. got=%d\n", 1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.LetStatement. got=%T", program.Statements[0])
+	}
+
+	function, ok := stmt.Value.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("stmt.Value is not ast.FunctionLiteral. got=%T", stmt.Value)
+	}
+
+	if function.Name != "myFunction" {
+		t.Fatalf("function literal name wrong. want 'myFunction', got=%q\n", function.Name)
+	}
+}
+
+func TestTailCallMarking(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []bool // 出現順の各呼び出しが末尾呼び出しか
+	}{
+		{"f(1)", []bool{false}},
+		{"fn() { f(1) }", []bool{true}},
+		{"fn() { f(1); g(2) }", []bool{false, true}},
+		{"fn() { return f(g(1)); }", []bool{true, false}},
+		{"fn() { if (a) { f() } else { g() } }", []bool{true, true}},
+		{"fn() { if (a) { return f(); } g() }", []bool{true, true}},
+		{"fn() { if (a) { f() } g() }", []bool{false, true}},
+		{"fn() { while (a) { f(); return g(); } }", []bool{false, true}},
+		{"fn() { let x = f(); x }", []bool{false}},
+		{"fn() { f() + 1 }", []bool{false}},
+		{"fn() { try { return f(); } catch (e) { g() } }", []bool{false, false}},
+		{"fn() { fn() { f() }; }", []bool{true}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		calls := []*ast.CallExpression{}
+		collectCalls(program, &calls)
+
+		if len(calls) != len(tt.expected) {
+			t.Fatalf("%q: wrong number of calls. expected=%d, got=%d", tt.input, len(tt.expected), len(calls))
+		}
+
+		for i, call := range calls {
+			if call.Tail != tt.expected[i] {
+				t.Errorf("%q: call %d (%s) Tail wrong. expected=%t, got=%t", tt.input, i, call, tt.expected[i], call.Tail)
+			}
+		}
+	}
+}
+
+// collectCalls 呼び出し式をソースコード上の出現順に収集する(テストで使用する構文のみ対応)。
+func collectCalls(node ast.Node, calls *[]*ast.CallExpression) {
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, s := range node.Statements {
+			collectCalls(s, calls)
+		}
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			collectCalls(s, calls)
+		}
+	case *ast.ExpressionStatement:
+		collectCalls(node.Expression, calls)
+	case *ast.ReturnStatement:
+		collectCalls(node.ReturnValue, calls)
+	case *ast.LetStatement:
+		collectCalls(node.Value, calls)
+	case *ast.WhileStatement:
+		collectCalls(node.Body, calls)
+	case *ast.InfixExpression:
+		collectCalls(node.Left, calls)
+		collectCalls(node.Right, calls)
+	case *ast.IfExpression:
+		collectCalls(node.Consequence, calls)
+		if node.Alternative != nil {
+			collectCalls(node.Alternative, calls)
+		}
+	case *ast.TryExpression:
+		collectCalls(node.Block, calls)
+		if node.Catch != nil {
+			collectCalls(node.Catch, calls)
+		}
+	case *ast.FunctionLiteral:
+		collectCalls(node.Body, calls)
+	case *ast.CallExpression:
+		*calls = append(*calls, node)
+		for _, a := range node.Arguments {
+			collectCalls(a, calls)
+		}
+	}
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := "add(1, 2 * 3, 4 + 5);"
 
@@ -656,6 +1132,37 @@ func TestStringLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestStringLiteralEscapes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a\nb"`, "a\nb"},
+		{`"\ttab\r"`, "\ttab\r"},
+		{`"say \"hi\""`, `say "hi"`},
+		{`"back\\slash"`, `back\slash`},
+		{`"\u{3042}\u{1F600}"`, "あ😀"},
+		{`"日本語"`, "日本語"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.StringLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.StringLiteral. got=%T", stmt.Expression)
+		}
+
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %q. got=%q", tt.expected, literal.Value)
+		}
+	}
+}
+
 func TestParsingEmptyArrayLiterals(t *testing.T) {
 	input := "[]"
 
@@ -774,6 +1281,20 @@ func TestParsingHashLiteralsStringKeys(t *testing.T) {
 		expectedValue := expected[literal.String()]
 		testIntegerLiteral(t, value, expectedValue)
 	}
+
+	// キーはソースコードでの出現順に並ぶ。
+	order := []string{"one", "two", "three"}
+	if len(hash.Keys) != len(order) {
+		t.Fatalf("hash.Keys has wrong length. got=%d", len(hash.Keys))
+	}
+	for i, key := range hash.Keys {
+		if key.String() != order[i] {
+			t.Errorf("hash.Keys[%d] is not %q. got=%q", i, order[i], key.String())
+		}
+	}
+	if hash.String() != "{one:1, two:2, three:3}" {
+		t.Errorf("hash.String() wrong. got=%q", hash.String())
+	}
 }
 
 func TestParsingHashLiteralsBooleanKeys(t *testing.T) {
@@ -895,6 +1416,56 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 	}
 }
 
+func TestParserErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x 5;", "main.monkey:1:7: expected next token to be =, got INT instead"},
+		{"let x = 1;\nadd(x;", "main.monkey:2:6: expected next token to be ), got ; instead"},
+		{"let x = 1;\n  let = 5;", "main.monkey:2:7: expected next token to be IDENT, got = instead"},
+		{"1 + ;", "main.monkey:1:5: no prefix parse function for ; found"},
+		{"a + b = 1;", "main.monkey:1:7: invalid assignment target: (a + b)"},
+		{"f() -= 1;", "main.monkey:1:5: invalid assignment target: f()"},
+		{`h?["a"] = 1;`, "main.monkey:1:9: invalid assignment target: (h?[a])"},
+		{"break;", "main.monkey:1:1: break outside loop"},
+		{"while (true) { let f = fn() { continue; }; }", "main.monkey:1:31: continue outside loop"},
+		{"for (x of y) {}", "main.monkey:1:8: expected next token to be IN, got IDENT instead"},
+		{"let x = 1;\n/* note", "main.monkey:2:1: unterminated block comment"},
+		{"let x = @;", "main.monkey:1:9: illegal token \"@\""},
+		{"let s = \"abc;\nputs(s);", "main.monkey:1:9: unterminated string"},
+		{`let s = "a\qb";`, `main.monkey:1:9: invalid escape sequence: \q`},
+		{`"\u{110000}"`, `main.monkey:1:1: invalid unicode escape: \u{110000}`},
+		{"try { f() }", "main.monkey:1:1: try without catch or finally"},
+		{"fn(a = 1, b) { }", "main.monkey:1:11: missing default value for parameter: b"},
+		{"fn(...a, b) { }", "main.monkey:1:10: rest parameter must be last"},
+		{"fn(...) { }", "main.monkey:1:7: expected next token to be IDENT, got ) instead"},
+		{"fn(a b) { }", "main.monkey:1:6: expected next token to be ), got IDENT instead"},
+		{"fn f { }", "main.monkey:1:6: expected next token to be (, got { instead"},
+		{"import x;", "main.monkey:1:8: expected next token to be STRING, got IDENT instead"},
+		{"export 1;", "main.monkey:1:8: expected let or function declaration after export, got INT"},
+		{"fn f() { export let x = 1; }", "main.monkey:1:10: export outside top level"},
+		{"if (true) { export fn f() { } }", "main.monkey:1:13: export outside top level"},
+		{"try { f() } catch e { }", "main.monkey:1:19: expected next token to be {, got IDENT instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewWithFilename("main.monkey", tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q", tt.input)
+			continue
+		}
+
+		if errors[0] != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, errors[0])
+		}
+	}
+}
+
 func testLetStatement(t *testing.T, s ast.Statement, name string) bool {
 	if s.TokenLiteral() != "let" {
 		t.Errorf("s.TokenLiteral not 'let'. got=%q", s.TokenLiteral())
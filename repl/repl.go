@@ -4,29 +4,61 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"monkey/compiler"
 	"monkey/evaluator"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
+	"monkey/token"
+	"monkey/vm"
+	"strings"
 )
 
 // prompt >>
 const prompt = ">> "
 
+// continuationPrompt 入力が継続している間に表示するプロンプト
+const continuationPrompt = ".. "
+
+// Engine 実行エンジン
+type Engine string
+
+const (
+	// EngineEval 木構造を辿って評価するインタプリタ
+	EngineEval Engine = "eval"
+	// EngineVM バイトコードにコンパイルして仮想マシンで実行する
+	EngineVM Engine = "vm"
+)
+
+// Options REPLおよびスクリプト実行時の設定
+type Options struct {
+	Engine Engine
+	// CheckedArithmetic 整数演算のオーバーフローをエラーとする(EngineEval のみ)。
+	CheckedArithmetic bool
+}
+
 // Start start REPL
-func Start(in io.Reader, out io.Writer) {
+func Start(in io.Reader, out io.Writer, opts Options) {
 	scanner := bufio.NewScanner(in)
+
 	env := object.NewEnvironment()
+	eval := evaluator.New()
+	eval.CheckedArithmetic = opts.CheckedArithmetic
+
+	constants := []object.Object{}
+	globals := make([]object.Object, vm.GlobalsSize)
+	symbolTable := compiler.NewSymbolTable()
+	for i, v := range object.Builtins {
+		symbolTable.DefineBuiltin(i, v.Name)
+	}
 
 	for {
-		fmt.Print(prompt)
-		scanned := scanner.Scan()
-		if !scanned {
+		input, ok := readInput(scanner, out)
+		if !ok {
 			return
 		}
 
-		line := scanner.Text()
-		l := lexer.New(line)
+		l := lexer.New(input)
 		p := parser.New(l)
 
 		program := p.ParseProgram()
@@ -35,16 +67,143 @@ func Start(in io.Reader, out io.Writer) {
 			continue
 		}
 
-		evaluated := evaluator.Eval(program, env)
+		var evaluated object.Object
+		switch opts.Engine {
+		case EngineVM:
+			// コンパイルに失敗した入力の定義が残らないよう、複製したテーブルでコンパイルする
+			table := symbolTable.Copy()
+			comp := compiler.NewWithState(table, constants)
+			if err := comp.Compile(program); err != nil {
+				_, err = io.WriteString(out, fmt.Sprintf("Woops! Compilation failed:\n %s\n", err))
+				printIOError(err)
+				continue
+			}
+			symbolTable = table
+
+			code := comp.Bytecode()
+			constants = code.Constants
+
+			machine := vm.NewWithGlobalsStore(code, globals)
+			if err := machine.Run(); err != nil {
+				_, err = io.WriteString(out, fmt.Sprintf("Woops! Executing bytecode failed:\n %s\n", err))
+				printIOError(err)
+				continue
+			}
+
+			evaluated = machine.LastPoppedStackElem()
+		default:
+			evaluated = eval.Eval(program, env)
+		}
+
 		if evaluated != nil {
 			_, err := io.WriteString(out, evaluated.Inspect())
 			printIOError(err)
 			_, err = io.WriteString(out, "\n")
 			printIOError(err)
 		}
+
+		if errObj, ok := evaluated.(*object.Error); ok {
+			_, err := io.WriteString(out, errObj.StackTrace())
+			printIOError(err)
+		}
 	}
 }
 
+// readInput 文として完結するまで入力を読み込む。
+// 継続中に空行が入力された場合は、完結していなくてもそこまでの入力を返却する。
+func readInput(scanner *bufio.Scanner, out io.Writer) (string, bool) {
+	lines := []string{}
+
+	for {
+		if len(lines) == 0 {
+			_, err := io.WriteString(out, prompt)
+			printIOError(err)
+		} else {
+			_, err := io.WriteString(out, continuationPrompt)
+			printIOError(err)
+		}
+
+		if !scanner.Scan() {
+			return "", false
+		}
+
+		line := scanner.Text()
+		if len(lines) > 0 && strings.TrimSpace(line) == "" {
+			return strings.Join(lines, "\n"), true
+		}
+
+		lines = append(lines, line)
+
+		input := strings.Join(lines, "\n")
+		if !isIncomplete(input) {
+			return input, true
+		}
+	}
+}
+
+// continuationTokens 入力の末尾にある場合に後続の入力を必要とするトークン
+var continuationTokens = map[token.Type]bool{
+	token.Assign:         true,
+	token.PlusAssign:     true,
+	token.MinusAssign:    true,
+	token.AsteriskAssign: true,
+	token.SlashAssign:    true,
+	token.Plus:           true,
+	token.Minus:          true,
+	token.Bang:           true,
+	token.Asterisk:       true,
+	token.Slash:          true,
+	token.Percent:        true,
+	token.Lt:             true,
+	token.Gt:             true,
+	token.LtEq:           true,
+	token.GtEq:           true,
+	token.Eq:             true,
+	token.NotEq:          true,
+	token.And:            true,
+	token.Or:             true,
+	token.Ampersand:      true,
+	token.Pipe:           true,
+	token.Caret:          true,
+	token.ShiftLeft:      true,
+	token.ShiftRight:     true,
+	token.NullCoalesce:   true,
+	token.Comma:          true,
+	token.Colon:          true,
+}
+
+// isIncomplete 入力が文として完結していないかを返却する。
+// 括弧の対応が取れていない場合、末尾が演算子の場合、文字列やブロックコメントが閉じられていない場合に完結していないとみなす。
+func isIncomplete(input string) bool {
+	l := lexer.New(input)
+
+	depth := 0
+	var last token.Token
+
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.Lparen, token.Lbrace, token.Lbracket, token.OptionalLbracket:
+			depth++
+		case token.Rparen, token.Rbrace, token.Rbracket:
+			depth--
+		case token.Illegal:
+			// 閉じられていないブロックコメント
+			if strings.HasPrefix(tok.Literal, "/*") {
+				return true
+			}
+			// 閉じられていない文字列
+			if strings.HasPrefix(tok.Literal, `"`) {
+				if _, err := lexer.Unquote(tok.Literal); err == lexer.ErrUnterminatedString {
+					return true
+				}
+			}
+		}
+		last = tok
+	}
+
+	return depth > 0 || continuationTokens[last.Type]
+}
+
 const monkeyFace = `            __,__
    .--.  .-"     "-.  .--.
   / .. \/  .-. .-.  \/ .. \
@@ -0,0 +1,99 @@
+package repl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestIsIncomplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"let x = 5;", false},
+		{"", false},
+		{"let add = fn(x, y) {", true},
+		{"let add = fn(x, y) {\n  x + y;\n};", false},
+		{"[1, 2,", true},
+		{"[1, 2,\n 3]", false},
+		{"add(1,", true},
+		{"(1 + 2", true},
+		{"1 +", true},
+		{"let x =", true},
+		{`{"a":`, true},
+		{`"hello`, true},
+		{`"hello"`, false},
+		{`""`, false},
+		{`puts("a", "b")`, false},
+		{"}", false},
+		{"let x = 5; // comment", false},
+		{"let x = // comment", true},
+		{"/* comment", true},
+		{"/* comment */ 1", false},
+		{"fn(x) { // {", true},
+		{`"say \"hi`, true},
+		{`"say \"hi\""`, false},
+		{`"a\qb"`, false},
+		{"x > 0 &&", true},
+		{"1 <<", true},
+		{"x ??", true},
+		{`h?["a"`, true},
+		{`h?["a"]`, false},
+	}
+
+	for _, tt := range tests {
+		if got := isIncomplete(tt.input); got != tt.expected {
+			t.Errorf("isIncomplete(%q) wrong. expected=%t, got=%t", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestStartMultiLineInput(t *testing.T) {
+	input := `let add = fn(x, y) {
+  x + y;
+};
+add(1,
+  2)
+let s = "abc
+`
+	out := &bytes.Buffer{}
+	Start(strings.NewReader(input), out, Options{Engine: EngineEval})
+
+	expected := ">> .. .. >> .. 3\n>> .. "
+	if out.String() != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, out.String())
+	}
+}
+
+func TestStartPrintsStackTrace(t *testing.T) {
+	input := `let inner = fn() { foo };
+let outer = fn() { inner() };
+outer()
+`
+	out := &bytes.Buffer{}
+	Start(strings.NewReader(input), out, Options{Engine: EngineEval})
+
+	expected := ">> >> >> ERROR: 1:20: identifier not found: foo\n" +
+		"  at inner (1:25)\n" +
+		"  at outer (1:6)\n" +
+		">> "
+	if out.String() != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, out.String())
+	}
+}
+
+func TestStartVMDiscardsFailedCompilation(t *testing.T) {
+	input := `let a = 1; b
+a + 1
+`
+	out := &bytes.Buffer{}
+	Start(strings.NewReader(input), out, Options{Engine: EngineVM})
+
+	expected := ">> Woops! Compilation failed:\n 1:12: identifier not found: b\n" +
+		">> Woops! Compilation failed:\n 1:1: identifier not found: a\n" +
+		">> "
+	if out.String() != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, out.String())
+	}
+}
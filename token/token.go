@@ -1,5 +1,7 @@
 package token
 
+import "fmt"
+
 // Type トークン種別
 type Type string
 
@@ -15,6 +17,8 @@ const (
 	Ident = "IDENT" // add, foobar, x, y, ...
 	// Int 整数値
 	Int = "INT" // 1343456
+	// Float 浮動小数点数
+	Float = "FLOAT" // 3.14, 1e-9
 	// String 文字列
 	String = "STRING" // "foobar"
 
@@ -22,6 +26,14 @@ const (
 
 	// Assign =
 	Assign = "="
+	// PlusAssign +=
+	PlusAssign = "+="
+	// MinusAssign -=
+	MinusAssign = "-="
+	// AsteriskAssign *=
+	AsteriskAssign = "*="
+	// SlashAssign /=
+	SlashAssign = "/="
 	// Plus +
 	Plus = "+"
 	// Minus -
@@ -32,16 +44,41 @@ const (
 	Asterisk = "*"
 	// Slash /
 	Slash = "/"
+	// Percent %
+	Percent = "%"
 
 	// Lt <
 	Lt = "<"
 	// Gt >
 	Gt = ">"
+	// LtEq <=
+	LtEq = "<="
+	// GtEq >=
+	GtEq = ">="
 	// Eq ==
 	Eq = "=="
 	// NotEq !=
 	NotEq = "!="
 
+	// And &&
+	And = "&&"
+	// Or ||
+	Or = "||"
+
+	// Ampersand &
+	Ampersand = "&"
+	// Pipe |
+	Pipe = "|"
+	// Caret ^
+	Caret = "^"
+	// ShiftLeft <<
+	ShiftLeft = "<<"
+	// ShiftRight >>
+	ShiftRight = ">>"
+
+	// NullCoalesce ??
+	NullCoalesce = "??"
+
 	// Delimiters
 
 	// Comma ,
@@ -50,6 +87,8 @@ const (
 	Semicolon = ";"
 	// Colon :
 	Colon = ":"
+	// Ellipsis ... (残余引数)
+	Ellipsis = "..."
 
 	// Lparen (
 	Lparen = "("
@@ -61,6 +100,8 @@ const (
 	Rbrace = "}"
 	// Lbracket [
 	Lbracket = "["
+	// OptionalLbracket ?[ (左辺が null の場合は null となる添字)
+	OptionalLbracket = "?["
 	// Rbracket ]
 	Rbracket = "]"
 
@@ -74,28 +115,98 @@ const (
 	True = "TRUE"
 	// False false
 	False = "FALSE"
+	// Null null
+	Null = "NULL"
 	// If if
 	If = "IF"
 	// Else else
 	Else = "ELSE"
 	// Return return
 	Return = "RETURN"
+	// While while
+	While = "WHILE"
+	// For for
+	For = "FOR"
+	// In in
+	In = "IN"
+	// Break break
+	Break = "BREAK"
+	// Continue continue
+	Continue = "CONTINUE"
+	// Throw throw
+	Throw = "THROW"
+	// Try try
+	Try = "TRY"
+	// Catch catch
+	Catch = "CATCH"
+	// Finally finally
+	Finally = "FINALLY"
+	// Import import
+	Import = "IMPORT"
+	// Export export
+	Export = "EXPORT"
 )
 
+// Position ソースコード上の位置
+type Position struct {
+	Filename string // ファイル名(存在しない場合は空文字)
+	Offset   int    // 先頭からのバイト位置(0始まり)
+	Line     int    // 行番号(1始まり)
+	Column   int    // 列番号(1始まり)
+}
+
+// IsValid 位置情報を保持しているかを返却する。
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+// String "file:line:col" 形式の文字列を返却する。ファイル名が存在しない場合は "line:col" となる。
+func (p Position) String() string {
+	s := p.Filename
+	if p.IsValid() {
+		if s != "" {
+			s += ":"
+		}
+		s += fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	if s == "" {
+		s = "-"
+	}
+	return s
+}
+
+// Comment ソースコード中のコメント
+type Comment struct {
+	Text string   // "//" や "/*" "*/" を含むコメントの原文
+	Pos  Position // コメントの開始位置
+}
+
 // Token 字句解析器(Lexer)より出力されるトークン。
 type Token struct {
-	Type    Type
-	Literal string
+	Type     Type
+	Literal  string
+	Pos      Position  // トークンの開始位置
+	Comments []Comment // トークンの直前にあるコメント(フォーマッタなどでの利用を想定)
 }
 
 var keywords = map[string]Type{
-	"fn":     Function,
-	"let":    Let,
-	"true":   True,
-	"false":  False,
-	"if":     If,
-	"else":   Else,
-	"return": Return,
+	"fn":       Function,
+	"let":      Let,
+	"true":     True,
+	"false":    False,
+	"null":     Null,
+	"if":       If,
+	"else":     Else,
+	"return":   Return,
+	"while":    While,
+	"for":      For,
+	"in":       In,
+	"break":    Break,
+	"continue": Continue,
+	"throw":    Throw,
+	"try":      Try,
+	"catch":    Catch,
+	"finally":  Finally,
+	"import":   Import,
+	"export":   Export,
 }
 
 // LookupIdent 与えられた識別子に対して適切なToken.Typeを返す。
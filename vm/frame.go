@@ -0,0 +1,23 @@
+package vm
+
+import (
+	"monkey/code"
+	"monkey/object"
+)
+
+// Frame 関数呼び出しごとの実行状態
+type Frame struct {
+	cl          *object.Closure
+	ip          int // 命令ポインタ
+	basePointer int // 呼び出し時のスタックポインタ
+}
+
+// NewFrame フレームを生成する。
+func NewFrame(cl *object.Closure, basePointer int) *Frame {
+	return &Frame{cl: cl, ip: -1, basePointer: basePointer}
+}
+
+// Instructions フレームで実行する命令列を返却する。
+func (f *Frame) Instructions() code.Instructions {
+	return f.cl.Fn.Instructions
+}
@@ -0,0 +1,796 @@
+package vm
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"monkey/code"
+	"monkey/compiler"
+	"monkey/object"
+)
+
+// StackSize スタックの最大要素数
+const StackSize = 2048
+
+// GlobalsSize グローバル変数の最大数
+const GlobalsSize = 65536
+
+// MaxFrames フレームの最大数
+const MaxFrames = 1024
+
+var (
+	null     = object.NullValue
+	trueObj  = object.True
+	falseObj = object.False
+)
+
+var operators = map[code.Opcode]string{
+	code.OpAdd:                "+",
+	code.OpSub:                "-",
+	code.OpMul:                "*",
+	code.OpDiv:                "/",
+	code.OpMod:                "%",
+	code.OpBitAnd:             "&",
+	code.OpBitOr:              "|",
+	code.OpBitXor:             "^",
+	code.OpShiftLeft:          "<<",
+	code.OpShiftRight:         ">>",
+	code.OpEqual:              "==",
+	code.OpNotEqual:           "!=",
+	code.OpGreaterThan:        ">",
+	code.OpGreaterThanOrEqual: ">=",
+	code.OpLessThan:           "<",
+	code.OpLessThanOrEqual:    "<=",
+}
+
+// VM バイトコードを実行する仮想マシン
+type VM struct {
+	constants []object.Object
+
+	stack []object.Object
+	sp    int // 常に次の空き領域を指す。スタックの先頭は stack[sp-1]
+
+	globals []object.Object
+
+	frames      []*Frame
+	framesIndex int
+}
+
+// New 仮想マシンを生成する。
+func New(bytecode *compiler.Bytecode) *VM {
+	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions}
+	mainClosure := &object.Closure{Fn: mainFn}
+	mainFrame := NewFrame(mainClosure, 0)
+
+	frames := make([]*Frame, MaxFrames)
+	frames[0] = mainFrame
+
+	return &VM{
+		constants: bytecode.Constants,
+
+		stack: make([]object.Object, StackSize),
+		sp:    0,
+
+		globals: make([]object.Object, GlobalsSize),
+
+		frames:      frames,
+		framesIndex: 1,
+	}
+}
+
+// NewWithGlobalsStore グローバル変数の領域を引き継いで仮想マシンを生成する。
+// REPLのように入力をまたいで束縛を保持する場合に使用する。
+func NewWithGlobalsStore(bytecode *compiler.Bytecode, s []object.Object) *VM {
+	vm := New(bytecode)
+	vm.globals = s
+	return vm
+}
+
+// LastPoppedStackElem 最後にスタックから取り除かれた要素を返却する。
+func (vm *VM) LastPoppedStackElem() object.Object {
+	return vm.stack[vm.sp]
+}
+
+// Run バイトコードを実行する。
+func (vm *VM) Run() error {
+	var ip int
+	var ins code.Instructions
+	var op code.Opcode
+
+	for vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		vm.currentFrame().ip++
+
+		ip = vm.currentFrame().ip
+		ins = vm.currentFrame().Instructions()
+		op = code.Opcode(ins[ip])
+
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			if err := vm.push(vm.constants[constIndex]); err != nil {
+				return err
+			}
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod,
+			code.OpBitAnd, code.OpBitOr, code.OpBitXor, code.OpShiftLeft, code.OpShiftRight:
+			if err := vm.executeBinaryOperation(op); err != nil {
+				return err
+			}
+
+		case code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpGreaterThanOrEqual, code.OpLessThan, code.OpLessThanOrEqual:
+			if err := vm.executeComparison(op); err != nil {
+				return err
+			}
+
+		case code.OpBang:
+			if err := vm.executeBangOperator(); err != nil {
+				return err
+			}
+
+		case code.OpMinus:
+			if err := vm.executeMinusOperator(); err != nil {
+				return err
+			}
+
+		case code.OpPop:
+			vm.pop()
+
+		case code.OpTrue:
+			if err := vm.push(trueObj); err != nil {
+				return err
+			}
+
+		case code.OpFalse:
+			if err := vm.push(falseObj); err != nil {
+				return err
+			}
+
+		case code.OpJump:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip = pos - 1
+
+		case code.OpJumpNotTruthy:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			condition := vm.pop()
+			if !isTruthy(condition) {
+				vm.currentFrame().ip = pos - 1
+			}
+
+		case code.OpJumpNull:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			if vm.stack[vm.sp-1] == null {
+				vm.currentFrame().ip = pos - 1
+			}
+
+		case code.OpJumpNotNull:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			if vm.stack[vm.sp-1] != null {
+				vm.currentFrame().ip = pos - 1
+			} else {
+				vm.pop()
+			}
+
+		case code.OpNull:
+			if err := vm.push(null); err != nil {
+				return err
+			}
+
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			vm.globals[globalIndex] = vm.pop()
+
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			global := vm.globals[globalIndex]
+			if global == nil {
+				return fmt.Errorf("uninitialized global variable: %d", globalIndex)
+			}
+
+			if err := vm.push(global); err != nil {
+				return err
+			}
+
+		case code.OpSetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip++
+
+			frame := vm.currentFrame()
+			vm.stack[frame.basePointer+int(localIndex)] = vm.pop()
+
+		case code.OpGetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip++
+
+			frame := vm.currentFrame()
+			if err := vm.push(vm.stack[frame.basePointer+int(localIndex)]); err != nil {
+				return err
+			}
+
+		case code.OpGetBuiltin:
+			builtinIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip++
+
+			definition := object.Builtins[builtinIndex]
+			if err := vm.push(definition.Builtin); err != nil {
+				return err
+			}
+
+		case code.OpGetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip++
+
+			currentClosure := vm.currentFrame().cl
+			if err := vm.push(currentClosure.Free[freeIndex]); err != nil {
+				return err
+			}
+
+		case code.OpCurrentClosure:
+			currentClosure := vm.currentFrame().cl
+			if err := vm.push(currentClosure); err != nil {
+				return err
+			}
+
+		case code.OpArray:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			array := vm.buildArray(vm.sp-numElements, vm.sp)
+			vm.sp = vm.sp - numElements
+
+			if err := vm.push(array); err != nil {
+				return err
+			}
+
+		case code.OpHash:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			hash, err := vm.buildHash(vm.sp-numElements, vm.sp)
+			if err != nil {
+				return err
+			}
+			vm.sp = vm.sp - numElements
+
+			if err := vm.push(hash); err != nil {
+				return err
+			}
+
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+
+			if err := vm.executeIndexExpression(left, index); err != nil {
+				return err
+			}
+
+		case code.OpCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip++
+
+			if err := vm.executeCall(int(numArgs)); err != nil {
+				return err
+			}
+
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+
+			if err := vm.push(returnValue); err != nil {
+				return err
+			}
+
+		case code.OpReturn:
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+
+			if err := vm.push(null); err != nil {
+				return err
+			}
+
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			numFree := code.ReadUint8(ins[ip+3:])
+			vm.currentFrame().ip += 3
+
+			if err := vm.pushClosure(int(constIndex), int(numFree)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) error {
+	if vm.framesIndex >= MaxFrames {
+		return fmt.Errorf("stack overflow")
+	}
+
+	vm.frames[vm.framesIndex] = f
+	vm.framesIndex++
+	return nil
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
+
+func (vm *VM) push(o object.Object) error {
+	if vm.sp >= StackSize {
+		return fmt.Errorf("stack overflow")
+	}
+
+	vm.stack[vm.sp] = o
+	vm.sp++
+
+	return nil
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+	return o
+}
+
+func (vm *VM) executeBinaryOperation(op code.Opcode) error {
+	right := vm.pop()
+	left := vm.pop()
+
+	leftType := left.Type()
+	rightType := right.Type()
+
+	switch {
+	case leftType == object.IntegerObj && rightType == object.IntegerObj:
+		return vm.executeBinaryIntegerOperation(op, left, right)
+	case isInteger(left) && isInteger(right):
+		return vm.executeBinaryBigIntOperation(op, left, right)
+	case isNumber(left) && isNumber(right):
+		return vm.executeBinaryFloatOperation(op, left, right)
+	case leftType == object.StringObj && rightType == object.StringObj:
+		return vm.executeBinaryStringOperation(op, left, right)
+	case leftType != rightType:
+		return fmt.Errorf("type mismatch: %s %s %s", leftType, operators[op], rightType)
+	default:
+		return fmt.Errorf("unknown operator: %s %s %s", leftType, operators[op], rightType)
+	}
+}
+
+func (vm *VM) executeBinaryIntegerOperation(op code.Opcode, left, right object.Object) error {
+	leftValue := left.(*object.Integer).Value
+	rightValue := right.(*object.Integer).Value
+
+	var result int64
+
+	switch op {
+	case code.OpAdd, code.OpSub, code.OpMul, code.OpShiftLeft:
+		if op == code.OpShiftLeft && rightValue < 0 {
+			return fmt.Errorf("negative shift count: %d", rightValue)
+		}
+		var ok bool
+		result, ok = object.IntArithmetic(operators[op], leftValue, rightValue)
+		if !ok {
+			return vm.executeBinaryBigIntOperation(op, left, right)
+		}
+	case code.OpDiv:
+		if rightValue == 0 {
+			return fmt.Errorf("division by zero: %d / %d", leftValue, rightValue)
+		}
+		if leftValue == math.MinInt64 && rightValue == -1 {
+			return vm.executeBinaryBigIntOperation(op, left, right)
+		}
+		result = leftValue / rightValue
+	case code.OpMod:
+		if rightValue == 0 {
+			return fmt.Errorf("division by zero: %d %% %d", leftValue, rightValue)
+		}
+		result = leftValue % rightValue
+	case code.OpShiftRight:
+		if rightValue < 0 {
+			return fmt.Errorf("negative shift count: %d", rightValue)
+		}
+		result = leftValue >> uint64(rightValue)
+	case code.OpBitAnd:
+		result = leftValue & rightValue
+	case code.OpBitOr:
+		result = leftValue | rightValue
+	case code.OpBitXor:
+		result = leftValue ^ rightValue
+	default:
+		return fmt.Errorf("unknown integer operator: %d", op)
+	}
+
+	return vm.push(&object.Integer{Value: result})
+}
+
+// executeBinaryBigIntOperation 少なくとも一方が int64 に収まらない整数同士の演算を行う。
+// 演算結果が int64 に収まる場合は Integer に戻す。
+func (vm *VM) executeBinaryBigIntOperation(op code.Opcode, left, right object.Object) error {
+	leftValue := object.ToBigInt(left)
+	rightValue := object.ToBigInt(right)
+
+	switch op {
+	case code.OpAdd, code.OpSub, code.OpMul, code.OpBitAnd, code.OpBitOr, code.OpBitXor:
+	case code.OpDiv, code.OpMod:
+		if rightValue.Sign() == 0 {
+			return fmt.Errorf("division by zero: %s %s %s", leftValue, operators[op], rightValue)
+		}
+	case code.OpShiftLeft, code.OpShiftRight:
+		if rightValue.Sign() < 0 {
+			return fmt.Errorf("negative shift count: %s", rightValue)
+		}
+		if !rightValue.IsInt64() || rightValue.Int64() > object.MaxShiftCount {
+			return fmt.Errorf("shift count too large: %s", rightValue)
+		}
+	default:
+		return fmt.Errorf("unknown integer operator: %d", op)
+	}
+
+	return vm.push(object.BigIntArithmetic(operators[op], leftValue, rightValue))
+}
+
+// executeBinaryFloatOperation 少なくとも一方が浮動小数点数である数値同士の演算を行う。
+// 整数は浮動小数点数に変換してから演算する。
+func (vm *VM) executeBinaryFloatOperation(op code.Opcode, left, right object.Object) error {
+	leftValue := toFloat(left)
+	rightValue := toFloat(right)
+
+	var result float64
+
+	switch op {
+	case code.OpAdd:
+		result = leftValue + rightValue
+	case code.OpSub:
+		result = leftValue - rightValue
+	case code.OpMul:
+		result = leftValue * rightValue
+	case code.OpDiv, code.OpMod:
+		if rightValue == 0 {
+			return fmt.Errorf("division by zero: %s %s %s", left.Inspect(), operators[op], right.Inspect())
+		}
+		if op == code.OpDiv {
+			result = leftValue / rightValue
+		} else {
+			result = math.Mod(leftValue, rightValue)
+		}
+	default:
+		return fmt.Errorf("unknown operator: %s %s %s", left.Type(), operators[op], right.Type())
+	}
+
+	return vm.push(&object.Float{Value: result})
+}
+
+func (vm *VM) executeBinaryStringOperation(op code.Opcode, left, right object.Object) error {
+	if op != code.OpAdd {
+		return fmt.Errorf("unknown operator: %s %s %s", left.Type(), operators[op], right.Type())
+	}
+
+	leftValue := left.(*object.String).Value
+	rightValue := right.(*object.String).Value
+
+	return vm.push(&object.String{Value: leftValue + rightValue})
+}
+
+func (vm *VM) executeComparison(op code.Opcode) error {
+	right := vm.pop()
+	left := vm.pop()
+
+	switch {
+	case left.Type() == object.IntegerObj && right.Type() == object.IntegerObj:
+		return vm.executeIntegerComparison(op, left, right)
+	case isInteger(left) && isInteger(right):
+		return vm.executeBigIntComparison(op, left, right)
+	case isNumber(left) && isNumber(right):
+		return vm.executeFloatComparison(op, left, right)
+	case left.Type() == object.StringObj && right.Type() == object.StringObj:
+		return fmt.Errorf("unknown operator: %s %s %s", left.Type(), operators[op], right.Type())
+	case left.Type() == object.HashObj && right.Type() == object.HashObj && (op == code.OpEqual || op == code.OpNotEqual):
+		equal := left.(*object.Hash).Equal(right.(*object.Hash))
+		return vm.push(nativeBoolToBooleanObject(equal == (op == code.OpEqual)))
+	case op == code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(right == left))
+	case op == code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(right != left))
+	case left.Type() != right.Type():
+		return fmt.Errorf("type mismatch: %s %s %s", left.Type(), operators[op], right.Type())
+	default:
+		return fmt.Errorf("unknown operator: %s %s %s", left.Type(), operators[op], right.Type())
+	}
+}
+
+func (vm *VM) executeIntegerComparison(op code.Opcode, left, right object.Object) error {
+	leftValue := left.(*object.Integer).Value
+	rightValue := right.(*object.Integer).Value
+
+	switch op {
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(rightValue == leftValue))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(rightValue != leftValue))
+	case code.OpGreaterThan:
+		return vm.push(nativeBoolToBooleanObject(leftValue > rightValue))
+	case code.OpGreaterThanOrEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue >= rightValue))
+	case code.OpLessThan:
+		return vm.push(nativeBoolToBooleanObject(leftValue < rightValue))
+	case code.OpLessThanOrEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue <= rightValue))
+	default:
+		return fmt.Errorf("unknown operator: %d", op)
+	}
+}
+
+func (vm *VM) executeBigIntComparison(op code.Opcode, left, right object.Object) error {
+	cmp := object.ToBigInt(left).Cmp(object.ToBigInt(right))
+
+	switch op {
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(cmp == 0))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(cmp != 0))
+	case code.OpGreaterThan:
+		return vm.push(nativeBoolToBooleanObject(cmp > 0))
+	case code.OpGreaterThanOrEqual:
+		return vm.push(nativeBoolToBooleanObject(cmp >= 0))
+	case code.OpLessThan:
+		return vm.push(nativeBoolToBooleanObject(cmp < 0))
+	case code.OpLessThanOrEqual:
+		return vm.push(nativeBoolToBooleanObject(cmp <= 0))
+	default:
+		return fmt.Errorf("unknown operator: %d", op)
+	}
+}
+
+func (vm *VM) executeFloatComparison(op code.Opcode, left, right object.Object) error {
+	leftValue := toFloat(left)
+	rightValue := toFloat(right)
+
+	switch op {
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(rightValue == leftValue))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(rightValue != leftValue))
+	case code.OpGreaterThan:
+		return vm.push(nativeBoolToBooleanObject(leftValue > rightValue))
+	case code.OpGreaterThanOrEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue >= rightValue))
+	case code.OpLessThan:
+		return vm.push(nativeBoolToBooleanObject(leftValue < rightValue))
+	case code.OpLessThanOrEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue <= rightValue))
+	default:
+		return fmt.Errorf("unknown operator: %d", op)
+	}
+}
+
+func (vm *VM) executeBangOperator() error {
+	operand := vm.pop()
+
+	switch operand {
+	case trueObj:
+		return vm.push(falseObj)
+	case falseObj:
+		return vm.push(trueObj)
+	case null:
+		return vm.push(trueObj)
+	default:
+		return vm.push(falseObj)
+	}
+}
+
+func (vm *VM) executeMinusOperator() error {
+	operand := vm.pop()
+
+	switch operand := operand.(type) {
+	case *object.Integer:
+		if operand.Value == math.MinInt64 {
+			return vm.push(object.NewBigInt(new(big.Int).Neg(big.NewInt(operand.Value))))
+		}
+		return vm.push(&object.Integer{Value: -operand.Value})
+	case *object.BigInt:
+		return vm.push(object.NewBigInt(new(big.Int).Neg(operand.Value)))
+	case *object.Float:
+		return vm.push(&object.Float{Value: -operand.Value})
+	default:
+		return fmt.Errorf("unknown operator: -%s", operand.Type())
+	}
+}
+
+func (vm *VM) buildArray(startIndex, endIndex int) object.Object {
+	elements := make([]object.Object, endIndex-startIndex)
+
+	for i := startIndex; i < endIndex; i++ {
+		elements[i-startIndex] = vm.stack[i]
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, error) {
+	hash := object.NewHash((endIndex - startIndex) / 2)
+
+	for i := startIndex; i < endIndex; i += 2 {
+		key := vm.stack[i]
+		value := vm.stack[i+1]
+
+		hashKey, ok := object.AsHashable(key)
+		if !ok {
+			return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+		}
+
+		hash.Set(hashKey, value)
+	}
+
+	return hash, nil
+}
+
+func (vm *VM) executeIndexExpression(left, index object.Object) error {
+	switch {
+	case left.Type() == object.ArrayObj && index.Type() == object.IntegerObj:
+		return vm.executeArrayIndex(left, index)
+	case left.Type() == object.HashObj:
+		return vm.executeHashIndex(left, index)
+	case left.Type() == object.StringObj && index.Type() == object.IntegerObj:
+		return vm.executeStringIndex(left, index)
+	default:
+		return fmt.Errorf("index operator not supported: %s", left.Type())
+	}
+}
+
+func (vm *VM) executeArrayIndex(array, index object.Object) error {
+	arrayObject := array.(*object.Array)
+	i := index.(*object.Integer).Value
+	max := int64(len(arrayObject.Elements) - 1)
+
+	if i < 0 || i > max {
+		return vm.push(null)
+	}
+
+	return vm.push(arrayObject.Elements[i])
+}
+
+// executeStringIndex 文字列の i 番目の文字(バイトではなくUnicodeのコードポイント)をスタックに積む。
+func (vm *VM) executeStringIndex(str, index object.Object) error {
+	runes := []rune(str.(*object.String).Value)
+	i := index.(*object.Integer).Value
+
+	if i < 0 || i >= int64(len(runes)) {
+		return vm.push(null)
+	}
+
+	return vm.push(&object.String{Value: string(runes[i])})
+}
+
+func (vm *VM) executeHashIndex(hash, index object.Object) error {
+	hashObject := hash.(*object.Hash)
+
+	key, ok := object.AsHashable(index)
+	if !ok {
+		return fmt.Errorf("unusable as hash key: %s", index.Type())
+	}
+
+	pair, ok := hashObject.Get(key)
+	if !ok {
+		return vm.push(null)
+	}
+
+	return vm.push(pair.Value)
+}
+
+func (vm *VM) executeCall(numArgs int) error {
+	callee := vm.stack[vm.sp-1-numArgs]
+	switch callee := callee.(type) {
+	case *object.Closure:
+		return vm.callClosure(callee, numArgs)
+	case *object.Builtin:
+		return vm.callBuiltin(callee, numArgs)
+	default:
+		return fmt.Errorf("not a function: %s", callee.Type())
+	}
+}
+
+func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
+	if numArgs != cl.Fn.NumParameters {
+		return fmt.Errorf("wrong number of arguments: want=%d, got=%d", cl.Fn.NumParameters, numArgs)
+	}
+
+	frame := NewFrame(cl, vm.sp-numArgs)
+	if err := vm.pushFrame(frame); err != nil {
+		return err
+	}
+
+	vm.sp = frame.basePointer + cl.Fn.NumLocals
+
+	return nil
+}
+
+func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
+	args := vm.stack[vm.sp-numArgs : vm.sp]
+
+	result := builtin.Fn(args...)
+	vm.sp = vm.sp - numArgs - 1
+
+	if errObj, ok := result.(*object.Error); ok {
+		return fmt.Errorf("%s", errObj.Message)
+	}
+
+	if result != nil {
+		return vm.push(result)
+	}
+	return vm.push(null)
+}
+
+func (vm *VM) pushClosure(constIndex int, numFree int) error {
+	constant := vm.constants[constIndex]
+	function, ok := constant.(*object.CompiledFunction)
+	if !ok {
+		return fmt.Errorf("not a function: %+v", constant)
+	}
+
+	free := make([]object.Object, numFree)
+	for i := 0; i < numFree; i++ {
+		free[i] = vm.stack[vm.sp-numFree+i]
+	}
+	vm.sp = vm.sp - numFree
+
+	closure := &object.Closure{Fn: function, Free: free}
+	return vm.push(closure)
+}
+
+func nativeBoolToBooleanObject(input bool) *object.Boolean {
+	if input {
+		return trueObj
+	}
+	return falseObj
+}
+
+func isInteger(obj object.Object) bool {
+	return obj.Type() == object.IntegerObj || obj.Type() == object.BigIntObj
+}
+
+func isNumber(obj object.Object) bool {
+	return isInteger(obj) || obj.Type() == object.FloatObj
+}
+
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.BigInt:
+		f, _ := new(big.Float).SetInt(obj.Value).Float64()
+		return f
+	case *object.Float:
+		return obj.Value
+	default:
+		return 0
+	}
+}
+
+func isTruthy(obj object.Object) bool {
+	switch obj := obj.(type) {
+	case *object.Boolean:
+		return obj.Value
+	case *object.Null:
+		return false
+	default:
+		return true
+	}
+}
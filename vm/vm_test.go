@@ -0,0 +1,789 @@
+package vm
+
+import (
+	"fmt"
+	"math/big"
+	"monkey/ast"
+	"monkey/compiler"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"testing"
+)
+
+type vmTestCase struct {
+	input    string
+	expected interface{}
+}
+
+func TestIntegerArithmetic(t *testing.T) {
+	tests := []vmTestCase{
+		{"5", 5},
+		{"10", 10},
+		{"-5", -5},
+		{"-10", -10},
+		{"5 + 5 + 5 + 5 - 10", 10},
+		{"2 * 2 * 2 * 2 * 2", 32},
+		{"-50 + 100 + -50", 0},
+		{"5 * 2 + 10", 20},
+		{"5 + 2 * 10", 25},
+		{"20 + 2 * -10", 0},
+		{"50 / 2 * 2 + 10", 60},
+		{"2 * (5 + 10)", 30},
+		{"3 * 3 * 3 + 10", 37},
+		{"3 * (3 * 3) + 10", 37},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"6 & 3", 2},
+		{"6 | 3", 7},
+		{"6 ^ 3", 5},
+		{"1 << 4", 16},
+		{"-16 >> 2", -4},
+		{"1 << 2 + 3 >> 1", 5},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestFloatArithmetic(t *testing.T) {
+	tests := []vmTestCase{
+		{"3.5", 3.5},
+		{"-2.5", -2.5},
+		{"1.5 + 1.5", 3.0},
+		{"1 + 0.5", 1.5},
+		{"0.5 + 1", 1.5},
+		{"10 - 2.5", 7.5},
+		{"2 * 1.25", 2.5},
+		{"1 / 4.0", 0.25},
+		{"7.0 / 2", 3.5},
+		{"1.5 < 2", true},
+		{"2 > 1.5", true},
+		{"1.0 == 1", true},
+		{"1 != 1.0", false},
+		{"7.5 % 2", 1.5},
+		{"2.5 >= 2.5", true},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestBigIntArithmetic(t *testing.T) {
+	tests := []vmTestCase{
+		{"9223372036854775808", newBigInt("9223372036854775808")},
+		{"-9223372036854775808", -9223372036854775808},
+		{"9223372036854775807 + 1", newBigInt("9223372036854775808")},
+		{"-9223372036854775807 - 2", newBigInt("-9223372036854775809")},
+		{"9223372036854775807 * 9223372036854775807", newBigInt("85070591730234615847396907784232501249")},
+		{"let min = -9223372036854775807 - 1; min / -1", newBigInt("9223372036854775808")},
+		{"let min = -9223372036854775807 - 1; -min", newBigInt("9223372036854775808")},
+		{"100000000000000000000 - 99999999999999999999", 1},
+		{"100000000000000000000 / 100", 1000000000000000000},
+		{"-(100000000000000000000)", newBigInt("-100000000000000000000")},
+		{"100000000000000000000 > 1", true},
+		{"1 > 100000000000000000000", false},
+		{"100000000000000000000 == 100000000000000000000", true},
+		{"100000000000000000000 != 100000000000000000000", false},
+		{"100000000000000000000 * 0.5", 5e19},
+		{"1 << 64", newBigInt("18446744073709551616")},
+		{"(1 << 64) >> 63", 2},
+		{"100000000000000000001 % 10", 1},
+		{"(1 << 64) | 1", newBigInt("18446744073709551617")},
+		{"100000000000000000000 >= 100000000000000000000", true},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestBooleanExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"true", true},
+		{"false", false},
+		{"1 < 2", true},
+		{"1 > 2", false},
+		{"1 < 1", false},
+		{"1 > 1", false},
+		{"1 == 1", true},
+		{"1 != 1", false},
+		{"1 == 2", false},
+		{"1 != 2", true},
+		{"true == true", true},
+		{"false == false", true},
+		{"true == false", false},
+		{"true != false", true},
+		{"false != true", true},
+		{"(1 < 2) == true", true},
+		{"(1 < 2) == false", false},
+		{"(1 > 2) == true", false},
+		{"(1 > 2) == false", true},
+		{"!true", false},
+		{"!false", true},
+		{"!5", false},
+		{"!!true", true},
+		{"!!false", false},
+		{"!!5", true},
+		{"!(if (false) { 5; })", true},
+		{`{"b": 1, "a": 2} == {"b": 1, "a": 2}`, true},
+		{`{"b": 1, "a": 2} == {"a": 2, "b": 1}`, false},
+		{`{"b": 1, "a": 2} != {"a": 2, "b": 1}`, true},
+		{`{"a": [1, 2]} == {"a": [1, 2]}`, true},
+		{`{1: 1} == {1: 2}`, false},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestLogicalExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"true && true", true},
+		{"true && false", false},
+		{"false && true", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 && 0", true},
+		{"1 <= 2 && 2 >= 3", false},
+		{"false && 1 / 0 == 0", false},
+		{"true || 1 / 0 == 0", true},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestNullExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"null", null},
+		{"null == null", true},
+		{"[1][5] == null", true},
+		{"null ?? 5", 5},
+		{"3 ?? 5", 3},
+		{"false ?? 5", false},
+		{"null ?? null ?? 7", 7},
+		{"1 ?? 1 / 0", 1},
+		{`let h = {"a": {"b": 2}}; h?["a"]?["b"]`, 2},
+		{`let h = {"a": {"b": 2}}; h?["x"]?["b"]`, null},
+		{`let h = null; h?["a"]?["b"]`, null},
+		{`let h = null; h?[1 / 0]`, null},
+		{`let h = {}; h?["a"] ?? "default"`, "default"},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestConditionals(t *testing.T) {
+	tests := []vmTestCase{
+		{"if (true) { 10 }", 10},
+		{"if (false) { 10 }", null},
+		{"if (1) { 10 }", 10},
+		{"if (1 < 2) { 10 }", 10},
+		{"if (1 > 2) { 10 }", null},
+		{"if (1 > 2) { 10 } else { 20 }", 20},
+		{"if (1 < 2) { 10 } else { 20 }", 10},
+		{"if ((if (false) { 10 })) { 10 } else { 20 }", 20},
+		// let 文で終わるブロックと空のブロックの値は null となる。
+		{"if (true) { let a = 1; }", null},
+		{"if (false) { 1 } else { let a = 1; }", null},
+		{"if (true) { }", null},
+		{"if (true) { let a = 1; a }", 1},
+		{"let b = if (true) { let a = 1; }; b", null},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestGlobalLetStatements(t *testing.T) {
+	tests := []vmTestCase{
+		{"let a = 5; a;", 5},
+		{"let a = 5 * 5; a;", 25},
+		{"let a = 5; let b = a; b;", 5},
+		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+		{"let a = 5; let a = a + 1; a;", 6},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestStringExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{`"Hello World!"`, "Hello World!"},
+		{`"Hello" + " " + "World!"`, "Hello World!"},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestArrayLiterals(t *testing.T) {
+	tests := []vmTestCase{
+		{"[]", []int{}},
+		{"[1, 2 * 2, 3 + 3]", []int{1, 4, 6}},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestHashLiterals(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			"{}", map[object.HashKey]int64{},
+		},
+		{
+			`let two = "two"; {"one": 10 - 9, two: 1 + 1, "thr" + "ee": 6 / 2, 4: 4, true: 5, false: 6}`,
+			map[object.HashKey]int64{
+				(&object.String{Value: "one"}).HashKey():   1,
+				(&object.String{Value: "two"}).HashKey():   2,
+				(&object.String{Value: "three"}).HashKey(): 3,
+				(&object.Integer{Value: 4}).HashKey():      4,
+				trueObj.HashKey():                          5,
+				falseObj.HashKey():                         6,
+			},
+		},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestIndexExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"[1, 2, 3][0]", 1},
+		{"[1, 2, 3][1]", 2},
+		{"[1, 2, 3][2]", 3},
+		{"let i = 0; [1][i];", 1},
+		{"[1, 2, 3][1 + 1];", 3},
+		{"let myArray = [1, 2, 3]; myArray[2];", 3},
+		{"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];", 6},
+		{"let myArray = [1, 2, 3]; let i = myArray[0]; myArray[i]", 2},
+		{"[1, 2, 3][3]", null},
+		{"[1, 2, 3][-1]", null},
+		{`{"foo": 5}["foo"]`, 5},
+		{`{"foo": 5}["bar"]`, null},
+		{`let key = "foo"; {"foo": 5}[key]`, 5},
+		{`{}["foo"]`, null},
+		{`{5: 5}[5]`, 5},
+		{`{true: 5}[true]`, 5},
+		{`{false: 5}[false]`, 5},
+		{`{1.5: 5}[1.5]`, 5},
+		{`{1: 5}[1.0]`, 5},
+		{`{2.0: 5}[2]`, 5},
+		{`{[1, "a"]: 5}[[1, "a"]]`, 5},
+		{`{[1, 2]: 5}[[2, 1]]`, null},
+		{`"abc"[1]`, "b"},
+		{`"日本語"[2]`, "語"},
+		{`"abc"[3]`, null},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestCallingFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{"let identity = fn(x) { x; }; identity(5);", 5},
+		{"let identity = fn(x) { return x; }; identity(5);", 5},
+		{"let double = fn(x) { x * 2; }; double(5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
+		{"fn(x) { x; }(5)", 5},
+		{"let noReturn = fn() { }; noReturn();", null},
+		{"fn() { if (true) { let a = 1 } }()", null},
+		{"fn() { let a = 2; if (a > 1) { let b = a } else { a } }()", null},
+		{"fn() { let a = 1 }()", null},
+		{"let f = fn(x) { return x; x + 10; }; f(10);", 10},
+		{"let f = fn(x) { let result = x + 10; return result; return 10; }; f(10);", 20},
+		{
+			`
+			let first = 10;
+			let second = 10;
+			let third = 10;
+
+			let ourFunction = fn(first) {
+				let second = 20;
+
+				first + second + third;
+			};
+
+			ourFunction(20) + first + second;`,
+			70,
+		},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len("hello world")`, 11},
+		{`len("日本語")`, 3},
+		{`len([1, 2, 3])`, 3},
+		{`len([])`, 0},
+		{`puts("hello", "world!")`, null},
+		{`first([1, 2, 3])`, 1},
+		{`first([])`, null},
+		{`last([1, 2, 3])`, 3},
+		{`last([])`, null},
+		{`rest([1, 2, 3])`, []int{2, 3}},
+		{`rest([])`, null},
+		{`push([], 1)`, []int{1}},
+		{`keys({3: 1, 1: 2, 2: 3})`, []int{3, 1, 2}},
+		{`values({3: 1, 1: 2, 2: 3})`, []int{1, 2, 3}},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestClosures(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			`
+			let newAdder = fn(x) {
+				fn(y) { x + y };
+			};
+
+			let addTwo = newAdder(2);
+			addTwo(2);`,
+			4,
+		},
+		{
+			`
+			let newAdderOuter = fn(a, b) {
+				let c = a + b;
+				fn(d) {
+					let e = d + c;
+					fn(f) { e + f; };
+				};
+			};
+			let newAdderInner = newAdderOuter(1, 2)
+			let adder = newAdderInner(3);
+			adder(8);`,
+			14,
+		},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestRecursiveFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			`
+			let countDown = fn(x) {
+				if (x == 0) {
+					return 0;
+				} else {
+					countDown(x - 1);
+				}
+			};
+			countDown(1);`,
+			0,
+		},
+		{
+			`
+			let wrapper = fn() {
+				let countDown = fn(x) {
+					if (x == 0) {
+						return 0;
+					} else {
+						countDown(x - 1);
+					}
+				};
+				countDown(1);
+			};
+			wrapper();`,
+			0,
+		},
+		{
+			`
+			let fibonacci = fn(x) {
+				if (x == 0) {
+					return 0;
+				} else {
+					if (x == 1) {
+						return 1;
+					} else {
+						fibonacci(x - 1) + fibonacci(x - 2);
+					}
+				}
+			};
+			fibonacci(15);`,
+			610,
+		},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"5 + true;", "type mismatch: INTEGER + BOOLEAN"},
+		{"5 + true; 5;", "type mismatch: INTEGER + BOOLEAN"},
+		{"-true", "unknown operator: -BOOLEAN"},
+		{"true + false;", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"true + false + true + false;", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"5; true + false; 5", "unknown operator: BOOLEAN + BOOLEAN"},
+		{`"Hello" - "World"`, "unknown operator: STRING - STRING"},
+		{"if (10 > 1) { true + false; }", "unknown operator: BOOLEAN + BOOLEAN"},
+		{`{"name": "Monkey"}[fn(x) { x }];`, "unusable as hash key: CLOSURE"},
+		{`len(1)`, "argument to `len` not supported, got INTEGER"},
+		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
+		{`first(1)`, "argument to `first` must be ARRAY, got INTEGER"},
+		{`push(1, 1)`, "argument to `push` must be ARRAY, got INTEGER"},
+		{"fn() { 1; }(1);", "wrong number of arguments: want=0, got=1"},
+		{"fn(a, b) { a + b; }(1);", "wrong number of arguments: want=2, got=1"},
+		{"1()", "not a function: INTEGER"},
+		{"1 / 0", "division by zero: 1 / 0"},
+		{"5 % 0", "division by zero: 5 % 0"},
+		{"1.5 / 0", "division by zero: 1.5 / 0"},
+		{"1 / 0.0", "division by zero: 1 / 0.0"},
+		{"5.5 % -0.0", "division by zero: 5.5 % -0.0"},
+		{"1 << -1", "negative shift count: -1"},
+		{"1 >> -1", "negative shift count: -1"},
+		{"1.5 & 1", "unknown operator: FLOAT & INTEGER"},
+		{`"a" < 1`, "type mismatch: STRING < INTEGER"},
+		{"fn() { 1 / 0 }() < fn() { true + 1 }()", "division by zero: 1 / 0"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err := vm.Run()
+		if err == nil {
+			t.Errorf("expected VM error for %q but resulted in none.", tt.input)
+			continue
+		}
+
+		if err.Error() != tt.expectedMessage {
+			t.Errorf("wrong VM error: want=%q, got=%q", tt.expectedMessage, err)
+		}
+	}
+}
+
+func runVMTests(t *testing.T, tests []vmTestCase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+
+		stackElem := vm.LastPoppedStackElem()
+
+		testExpectedObject(t, tt.input, tt.expected, stackElem)
+	}
+}
+
+func parse(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
+
+func testExpectedObject(t *testing.T, input string, expected interface{}, actual object.Object) {
+	t.Helper()
+
+	switch expected := expected.(type) {
+	case int:
+		if err := testIntegerObject(int64(expected), actual); err != nil {
+			t.Errorf("testIntegerObject failed for %q: %s", input, err)
+		}
+
+	case *big.Int:
+		bigInt, ok := actual.(*object.BigInt)
+		if !ok {
+			t.Errorf("object is not BigInt for %q. got=%T (%+v)", input, actual, actual)
+			return
+		}
+		if bigInt.Value.Cmp(expected) != 0 {
+			t.Errorf("object has wrong value for %q. got=%s, want=%s", input, bigInt.Value, expected)
+		}
+
+	case float64:
+		if err := testFloatObject(expected, actual); err != nil {
+			t.Errorf("testFloatObject failed for %q: %s", input, err)
+		}
+
+	case bool:
+		if err := testBooleanObject(expected, actual); err != nil {
+			t.Errorf("testBooleanObject failed for %q: %s", input, err)
+		}
+
+	case string:
+		if err := testStringObject(expected, actual); err != nil {
+			t.Errorf("testStringObject failed for %q: %s", input, err)
+		}
+
+	case []int:
+		array, ok := actual.(*object.Array)
+		if !ok {
+			t.Errorf("object not Array for %q: %T (%+v)", input, actual, actual)
+			return
+		}
+
+		if len(array.Elements) != len(expected) {
+			t.Errorf("wrong num of elements for %q. want=%d, got=%d", input, len(expected), len(array.Elements))
+			return
+		}
+
+		for i, expectedElem := range expected {
+			if err := testIntegerObject(int64(expectedElem), array.Elements[i]); err != nil {
+				t.Errorf("testIntegerObject failed for %q: %s", input, err)
+			}
+		}
+
+	case map[object.HashKey]int64:
+		hash, ok := actual.(*object.Hash)
+		if !ok {
+			t.Errorf("object is not Hash for %q. got=%T (%+v)", input, actual, actual)
+			return
+		}
+
+		if hash.Len() != len(expected) {
+			t.Errorf("hash has wrong number of Pairs for %q. want=%d, got=%d", input, len(expected), hash.Len())
+			return
+		}
+
+		for _, pair := range hash.Pairs() {
+			expectedValue, ok := expected[pair.Key.(object.Hashable).HashKey()]
+			if !ok {
+				t.Errorf("unexpected key %s in Pairs for %q", pair.Key.Inspect(), input)
+				continue
+			}
+
+			if err := testIntegerObject(expectedValue, pair.Value); err != nil {
+				t.Errorf("testIntegerObject failed for %q: %s", input, err)
+			}
+		}
+
+	case *object.Null:
+		if actual != null {
+			t.Errorf("object is not Null for %q: %T (%+v)", input, actual, actual)
+		}
+	}
+}
+
+func testIntegerObject(expected int64, actual object.Object) error {
+	result, ok := actual.(*object.Integer)
+	if !ok {
+		return fmt.Errorf("object is not Integer. got=%T (%+v)", actual, actual)
+	}
+
+	if result.Value != expected {
+		return fmt.Errorf("object has wrong value. got=%d, want=%d", result.Value, expected)
+	}
+
+	return nil
+}
+
+func testFloatObject(expected float64, actual object.Object) error {
+	result, ok := actual.(*object.Float)
+	if !ok {
+		return fmt.Errorf("object is not Float. got=%T (%+v)", actual, actual)
+	}
+
+	if result.Value != expected {
+		return fmt.Errorf("object has wrong value. got=%g, want=%g", result.Value, expected)
+	}
+
+	return nil
+}
+
+func testBooleanObject(expected bool, actual object.Object) error {
+	result, ok := actual.(*object.Boolean)
+	if !ok {
+		return fmt.Errorf("object is not Boolean. got=%T (%+v)", actual, actual)
+	}
+
+	if result.Value != expected {
+		return fmt.Errorf("object has wrong value. got=%t, want=%t", result.Value, expected)
+	}
+
+	return nil
+}
+
+func testStringObject(expected string, actual object.Object) error {
+	result, ok := actual.(*object.String)
+	if !ok {
+		return fmt.Errorf("object is not String. got=%T (%+v)", actual, actual)
+	}
+
+	if result.Value != expected {
+		return fmt.Errorf("object has wrong value. got=%q, want=%q", result.Value, expected)
+	}
+
+	return nil
+}
+
+func newBigInt(s string) *big.Int {
+	v, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		panic("invalid big integer: " + s)
+	}
+	return v
+}
+
+func TestUninitializedGlobal(t *testing.T) {
+	symbolTable := compiler.NewSymbolTable()
+	constants := []object.Object{}
+	globals := make([]object.Object, GlobalsSize)
+
+	for i, input := range []string{"let a = 1 / 0;", "a"} {
+		comp := compiler.NewWithState(symbolTable, constants)
+		if err := comp.Compile(parse(input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		constants = comp.Bytecode().Constants
+
+		err := NewWithGlobalsStore(comp.Bytecode(), globals).Run()
+		if err == nil {
+			t.Fatalf("expected VM error for %q but resulted in none.", input)
+		}
+
+		expected := []string{"division by zero: 1 / 0", "uninitialized global variable: 0"}[i]
+		if err.Error() != expected {
+			t.Errorf("wrong VM error: want=%q, got=%q", expected, err)
+		}
+	}
+}
+
+// engineParityTest 両方のエンジンで同じ結果になるべき入力
+// unsupported には VM が未対応の機能名を設定し、設定された入力は VM での実行を省略する。
+type engineParityTest struct {
+	input       string
+	unsupported string
+}
+
+func TestEngineParity(t *testing.T) {
+	tests := []engineParityTest{
+		// 数値
+		{input: "5 + 5 * 2 - 10 / 2"},
+		{input: "-50 + 100 + -50"},
+		{input: "7 % 3 + (1 << 4) - (256 >> 2)"},
+		{input: "6 & 3 | 8 ^ 1"},
+		{input: "2.5 * 2 + 1"},
+		{input: "1 / 0"},
+		{input: "1.5 / 0"},
+		{input: "2 % 0.0"},
+		{input: "9223372036854775807 + 1"},
+		{input: "-9223372036854775808 - 1"},
+		{input: "10000000000000000000 * 10000000000000000000"},
+		// 比較と論理演算
+		{input: "1 < 2"},
+		{input: "2 <= 2"},
+		{input: "1.5 > 1"},
+		{input: "1 == 1.0"},
+		{input: "(1 < 2) == true"},
+		{input: `"a" < 1`},
+		{input: "!!5"},
+		{input: "false && 1 / 0"},
+		{input: "true || 1 / 0"},
+		{input: "null ?? 5"},
+		// if 式
+		{input: "if (1 < 2) { 10 } else { 20 }"},
+		{input: "if (false) { 10 }"},
+		{input: "if (true) { let a = 1; }"},
+		// let 文と関数
+		{input: "let a = 5; let b = a * 2; b + a"},
+		{input: "let add = fn(x, y) { x + y }; add(1, add(2, 3))"},
+		{input: "fn() { return 1; 2 }()"},
+		{input: "let f = fn(x) { fn(y) { x + y } }; f(1)(2)"},
+		{input: "let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(15)"},
+		{input: "fn(a) { a }()"},
+		{input: "let f = fn(a) { 1 / a }; f(1) < f(0)"},
+		{input: "fn() { 1 / 0 }() < fn() { 1 + true }()"},
+		{input: "fn() { 1 / 0 }() <= fn() { 1 + true }()"},
+		// 文字列、配列、ハッシュ
+		{input: `"foo" + "bar"`},
+		{input: `"abc"[1]`},
+		{input: "[1, 2 * 2, 3 + 3][1]"},
+		{input: "[1, 2, 3][5]"},
+		{input: `{"a": 1, 2: "b", true: 3}`},
+		{input: `{"a": 1}["a"]`},
+		{input: `{[1, 2]: "x"}[[1, 2]]`},
+		{input: `{"a": 1, "b": 2} == {"a": 1, "b": 2}`},
+		{input: `{"a": 1, "b": 2} == {"b": 2, "a": 1}`},
+		// 組み込み関数
+		{input: `len("four") + len([1, 2])`},
+		{input: "push(rest([1, 2, 3]), 4)"},
+		{input: `keys({"b": 1, "a": 2})`},
+		{input: "len(1)"},
+		// VM が未対応の機能
+		{input: "let a = 1; a = 2; a", unsupported: "assignment"},
+		{input: "let i = 0; while (i < 3) { i = i + 1 } i", unsupported: "while"},
+		{input: "let s = 0; for (x in [1, 2]) { s = s + x } s", unsupported: "for"},
+		{input: `try { throw "e" } catch (e) { e }`, unsupported: "try/throw"},
+		{input: "fn add(x, y) { x + y } add(1, 2)", unsupported: "function statement"},
+		{input: "let f = fn(x, y = 2) { x + y }; f(1)", unsupported: "default parameter"},
+		{input: "let f = fn(...rest) { len(rest) }; f(1, 2)", unsupported: "rest parameter"},
+		{input: `import "math"`, unsupported: "import"},
+	}
+
+	runEngineParityTests(t, tests)
+}
+
+// runEngineParityTests 同じ入力を evaluator と compiler+vm の両方で実行し、結果を比較する。
+// エラーの場合は、どちらのエンジンでもエラーになりメッセージが一致することを確認する。
+func runEngineParityTests(t *testing.T, tests []engineParityTest) {
+	t.Helper()
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.input, func(t *testing.T) {
+			if tt.unsupported != "" {
+				t.Skipf("vm does not support %s", tt.unsupported)
+			}
+
+			evaluated := evaluator.Eval(parse(tt.input), object.NewEnvironment())
+			if evaluated == nil {
+				// evaluator は let 文で終わるブロックの値を Go の nil で表す
+				evaluated = null
+			}
+
+			comp := compiler.New()
+			if err := comp.Compile(parse(tt.input)); err != nil {
+				t.Fatalf("compiler error: %s", err)
+			}
+
+			machine := New(comp.Bytecode())
+			err := machine.Run()
+
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if err == nil {
+					t.Fatalf("expected VM error %q but resulted in none. got=%s",
+						errObj.Message, machine.LastPoppedStackElem().Inspect())
+				}
+				if err.Error() != errObj.Message {
+					t.Errorf("wrong VM error: eval=%q, vm=%q", errObj.Message, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("vm error: %s (eval=%s)", err, evaluated.Inspect())
+			}
+
+			result := machine.LastPoppedStackElem()
+			if result.Type() != evaluated.Type() || result.Inspect() != evaluated.Inspect() {
+				t.Errorf("engines disagree: eval=%s (%s), vm=%s (%s)",
+					evaluated.Inspect(), evaluated.Type(), result.Inspect(), result.Type())
+			}
+		})
+	}
+}